@@ -0,0 +1,106 @@
+package asyncq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gopherd/core/event"
+)
+
+var (
+	// ErrFull is returned when the asyncq queue is at capacity.
+	ErrFull = errors.New("asyncq: queue is at capacity")
+
+	// ErrClosed is returned when trying to send an event to a non-running component.
+	ErrClosed = errors.New("asyncq: component is closed")
+)
+
+// Component represents the asyncq component API.
+type Component[T comparable] interface {
+	event.EventSystem[T]
+
+	// SetOverflowHandler sets the handler for events that do not fit in the
+	// queue in "callback" overflow mode.
+	SetOverflowHandler(handler OverflowHandler[T])
+
+	// SetDeadLetterHandler sets the handler for events whose listeners still
+	// return an error or panic after all retries, see Options.Retry. If no
+	// handler is set, the failed events are logged and discarded.
+	SetDeadLetterHandler(handler DeadLetterHandler[T])
+
+	// AddBatchListener adds a listener receiving the events of its type in
+	// batches, and returns its ID for RemoveBatchListener. A batch contains
+	// up to BatchSize consecutive events of the type in the queue, or those
+	// arrived within BatchLinger. The listeners added by AddListener are still
+	// called for each event of the batch, before the batch listeners.
+	AddBatchListener(listener BatchListener[T]) event.ListenerID
+
+	// RemoveBatchListener removes the batch listener by ID. It returns false
+	// if the listener is not found.
+	RemoveBatchListener(id event.ListenerID) bool
+
+	// DispatchAfter dispatches the event after the duration d. The timer is
+	// cancelled if ctx is done before it fires.
+	DispatchAfter(ctx context.Context, e event.Event[T], d time.Duration) (Timer, error)
+
+	// DispatchAt dispatches the event at the time t. The timer is cancelled
+	// if ctx is done before it fires.
+	DispatchAt(ctx context.Context, e event.Event[T], t time.Time) (Timer, error)
+
+	// SetCodec sets the codec of events for the write-ahead log. It must be
+	// called before the component is initialized, when the logged events are
+	// decoded and queued ahead of any new event, e.g. by a component
+	// initialized earlier.
+	// The default codec uses encoding/gob, which requires the event types to
+	// be registered by event.Register.
+	SetCodec(codec Codec[T])
+
+	// Stats returns the statistics of the queue.
+	Stats() Stats
+}
+
+// Codec encodes and decodes events for the write-ahead log.
+type Codec[T comparable] interface {
+	// Encode encodes the event.
+	Encode(e event.Event[T]) ([]byte, error)
+	// Decode decodes an event encoded by Encode.
+	Decode(data []byte) (event.Event[T], error)
+}
+
+// Timer represents a delayed event scheduled by DispatchAfter or DispatchAt.
+type Timer interface {
+	// Cancel cancels the timer. It returns false if the event has already
+	// been dispatched or the timer has already been cancelled.
+	Cancel() bool
+}
+
+// OverflowHandler handles an event that does not fit in the queue, e.g. by
+// spilling it to an external store. The returned error is returned from
+// DispatchEvent.
+type OverflowHandler[T comparable] func(ctx context.Context, e event.Event[T]) error
+
+// DeadLetterHandler handles an event that failed to be dispatched, e.g. by
+// storing it for manual inspection. err is the error returned from the
+// listeners in the last attempt, or a *PanicError if a listener panicked.
+type DeadLetterHandler[T comparable] func(ctx context.Context, e event.Event[T], err error)
+
+// PanicError represents a panic recovered from a listener.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("asyncq: listener panicked: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
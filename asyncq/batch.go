@@ -0,0 +1,43 @@
+package asyncq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gopherd/core/event"
+)
+
+// BatchListener handles events of a type in batches, see Component.AddBatchListener.
+type BatchListener[T comparable] interface {
+	// EventType returns the type of events this listener handles.
+	EventType() T
+	// HandleEvents processes a batch of events in dispatch order.
+	HandleEvents(ctx context.Context, events []event.Event[T]) error
+}
+
+// ListenBatch creates a BatchListener for the given event type and handler function.
+func ListenBatch[H ~func(context.Context, []E) error, E event.Event[T], T comparable](eventType T, handler H) BatchListener[T] {
+	return batchListenerFunc[H, E, T]{eventType, handler}
+}
+
+type batchListenerFunc[H ~func(context.Context, []E) error, E event.Event[T], T comparable] struct {
+	eventType T
+	handler   H
+}
+
+// EventType implements the BatchListener interface.
+func (h batchListenerFunc[H, E, T]) EventType() T {
+	return h.eventType
+}
+
+// HandleEvents implements the BatchListener interface.
+func (h batchListenerFunc[H, E, T]) HandleEvents(ctx context.Context, events []event.Event[T]) error {
+	es := make([]E, len(events))
+	for i, e := range events {
+		var ok bool
+		if es[i], ok = e.(E); !ok {
+			return fmt.Errorf("%w: got %T for type %v", event.ErrUnexpectedEventType, e, e.Typeof())
+		}
+	}
+	return h.handler(ctx, es)
+}
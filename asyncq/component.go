@@ -2,8 +2,10 @@
 
 package asyncq
 
+import "github.com/gopherd/core/typing"
 import "github.com/gopherd/core/op"
 
+var _ = (*typing.Duration)(nil)
 var _ = op.SetDefault[any]
 
 // Name represents the asyncq component name.
@@ -11,15 +13,109 @@ const Name = "github.com/gopherd/components/asyncq";
 
 // Options represents the component options.
 type Options struct {
-	// LockThread determines if the consumer goroutine should be bound to an OS thread.
+	// LockThread determines if each consumer goroutine should be bound to an OS thread.
 	LockThread bool
 	// MaxSize is the maximum number of requests allowed in the queue.
-	// Requests exceeding this limit will be discarded.
+	// Requests exceeding this limit are handled according to Overflow.
 	MaxSize int
+	// Overflow specifies how to handle requests when the queue is full.
+	// Supported values:
+	//   - "drop-newest": the request is discarded and DispatchEvent returns asyncq.ErrFull
+	//   - "drop-oldest": the oldest request in the queue is discarded to make room
+	//   - "block": DispatchEvent blocks until there is room or its context is done
+	//   - "callback": the request is passed to the handler set by SetOverflowHandler
+	Overflow string
 	// NumConsumers is the number of consumer goroutines to run concurrently.
+	// It is ignored in "fifo" ordering mode.
 	NumConsumers int
+	// Ordering specifies the order in which events are consumed.
+	// Supported values:
+	//   - "fifo": events are consumed in dispatch order by a single consumer
+	//   - "unordered": events are consumed by all consumers without ordering guarantees
+	//   - "keyed": events with the same key are consumed in order by the same consumer,
+	//     see asyncq.KeyedEvent
+	Ordering string
+	// PriorityWeights specifies the weights of the priority lanes, from the lowest
+	// priority to the highest, see asyncq.PriorityEvent. When several lanes have
+	// pending events, each lane is drained in proportion to its weight, so that
+	// the low lanes are never starved. If empty, all events share a single lane.
+	PriorityWeights []int
+	// DrainTimers determines whether the pending delayed events are dispatched
+	// on shutdown instead of being discarded.
+	DrainTimers bool
+	// ShutdownTimeout is the maximum duration of draining the queues on shutdown
+	// if the Uninit context has no deadline, as is the case when the component is
+	// run by the service. The drain is not bounded if 0.
+	ShutdownTimeout typing.Duration
+	// WAL specifies the write-ahead log for persisting the queued events.
+	WAL WALOptions
+	// Retry specifies the retry policy for events whose listeners return an error
+	// or panic. Events still failing after all retries are passed to the handler
+	// set by SetDeadLetterHandler. Retries stop on shutdown, or at the shutdown
+	// deadline if the WAL is enabled, when the failed events are kept in the log.
+	Retry RetryOptions
+	// BatchSize is the maximum number of events passed to a batch listener at once,
+	// see Component.AddBatchListener.
+	BatchSize int
+	// BatchLinger is the time to wait for more events of the same type when the
+	// queue runs empty before a batch is full. The batch is dispatched as soon as
+	// the queue runs empty if 0.
+	BatchLinger typing.Duration
+	// HTTPPath specifies the root HTTP path to get the queue statistics.
+	// If empty, the HTTP handler is not registered.
+	//
+	// - get statistics: GET {HTTPPath}/stats
+	HTTPPath string
 }
 
 func (x *Options) OnLoaded() {
 	op.SetDefault(&x.MaxSize, 1048576)
+	op.SetDefault(&x.Overflow, "drop-newest")
+	op.SetDefault(&x.NumConsumers, 1)
+	op.SetDefault(&x.Ordering, "fifo")
+	op.SetDefault(&x.BatchSize, 100)
 }
+
+// WALOptions represents the write-ahead log configuration. Enqueued events are
+// appended to the log and acknowledged after they are dispatched. Events not
+// acknowledged are queued again ahead of new events when the component is
+// initialized, so an event may be dispatched more than once after a crash.
+// With the log enabled, events are dispatched from Start instead of Init.
+type WALOptions struct {
+	// Dir is the directory of the log segment files. The log is disabled if empty.
+	Dir string
+	// SegmentSize is the size in bytes at which a new segment file is started.
+	SegmentSize int
+	// Sync specifies when the log is synced to disk.
+	// Supported values:
+	//   - "always": after each record is appended
+	//   - "interval": every SyncInterval
+	//   - "none": left to the operating system
+	Sync string
+	// SyncInterval is the interval for syncing the log in "interval" mode.
+	// Default is 1 second.
+	SyncInterval typing.Duration
+}
+
+func (x *WALOptions) OnLoaded() {
+	op.SetDefault(&x.SegmentSize, 67108864)
+	op.SetDefault(&x.Sync, "interval")
+}
+
+
+// RetryOptions represents the retry policy for failed events.
+type RetryOptions struct {
+	// MaxRetries is the maximum number of times a failed event is dispatched again.
+	// All listeners of the event are called on each retry, so they should be
+	// idempotent. Failed events are not retried if 0.
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubled on each retry.
+	// Default is 100 milliseconds.
+	Backoff typing.Duration
+	// MaxBackoff is the maximum delay between retries.
+	// Default is 10 seconds.
+	MaxBackoff typing.Duration
+}
+
+func (x *RetryOptions) OnLoaded() {
+}
\ No newline at end of file
@@ -0,0 +1,20 @@
+package asyncq
+
+// KeyedEvent is an optional interface implemented by events to choose the
+// consumer in "keyed" ordering mode. Events with the same key are consumed in
+// dispatch order by the same consumer goroutine. Events that do not implement
+// KeyedEvent are spread across consumers without ordering guarantees.
+type KeyedEvent interface {
+	// EventKey returns the ordering key of the event.
+	EventKey() string
+}
+
+// PriorityEvent is an optional interface implemented by events to choose the
+// priority lane. Events with higher priority are consumed ahead of events with
+// lower priority queued in the same component, as weighted by the
+// PriorityWeights option. Priorities are clamped to the range of lanes, and
+// events that do not implement PriorityEvent have priority 0.
+type PriorityEvent interface {
+	// EventPriority returns the priority of the event, starting from 0.
+	EventPriority() int
+}
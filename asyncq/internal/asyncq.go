@@ -1,15 +1,24 @@
 package internal
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/maphash"
 	"log/slog"
+	"net/http"
+	"path"
 	"reflect"
 	"runtime"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gopherd/components/asyncq"
+	"github.com/gopherd/components/httpserver"
 	"github.com/gopherd/core/component"
 	"github.com/gopherd/core/event"
 	"github.com/gopherd/core/lifecycle"
@@ -23,110 +32,595 @@ func init() {
 
 var (
 	// ErrFull is returned when the asyncq queue is at capacity.
-	ErrFull = errors.New("asyncq: queue is at capacity")
+	ErrFull = asyncq.ErrFull
 
 	// ErrClosed is returned when trying to send an event to a non-running component.
-	ErrClosed = errors.New("asyncq: component is closed")
+	ErrClosed = asyncq.ErrClosed
 )
 
-// Ensure asyncqComponent implements asyncq.Component interface.
-var _ event.EventSystem[reflect.Type] = (*AsyncqComponent[reflect.Type])(nil)
+// Ensure AsyncqComponent implements asyncq.Component interface.
+var _ asyncq.Component[reflect.Type] = (*AsyncqComponent[reflect.Type])(nil)
 
 // AsyncqComponent implements the asyncq.Component interface for handling asynchronous events.
 type AsyncqComponent[T comparable] struct {
-	component.BaseComponent[asyncq.Options]
-	eventSystem event.EventSystem[T]
+	component.BaseComponentWithRefs[asyncq.Options, struct {
+		HTTPServer component.OptionalReference[httpserver.Component]
+	}]
+	eventSystem    event.EventSystem[T]
+	batchListeners batchListeners[T]
 
-	mutex sync.Mutex
-	queue *queue[T]
-	cond  *sync.Cond
+	shards []*shard[T]        // Event queues, one per consumer in keyed mode
+	ctx    context.Context    // Context for dispatching events, cancelled when the shutdown deadline is exceeded
+	cancel context.CancelFunc // Cancels ctx
+	seed   maphash.Seed       // Seed for hashing event keys
+	next   atomic.Uint64      // Round-robin counter for events without key
+	wg     sync.WaitGroup     // Tracks consumer goroutines
+	mutex  sync.Mutex         // Guards the fields below
+	size   int                // Total number of requests in the queues
+	peak   int                // Peak number of requests in the queues
+	space  chan struct{}      // Closed when a request is removed from the queues, nil if no one is waiting
 
-	status     int32         // Running status
-	quit, wait chan struct{} // Channels for shutdown signaling and completion
+	overflowHandler   asyncq.OverflowHandler[T]   // Handler for requests exceeding MaxSize in callback mode
+	deadLetterHandler asyncq.DeadLetterHandler[T] // Handler for events failed after all retries
 
-	maxSizeEver int // Peak number of requests in the queue
+	timers *timerQueue[T] // Delayed events scheduled by DispatchAt
+	stats  *stats[T]      // Event statistics
+
+	codec asyncq.Codec[T] // Codec of events for the write-ahead log, nil for gob
+	wal   *wal            // Write-ahead log, nil if disabled
+
+	status  int32         // Running status
+	started chan struct{} // Closed when the component starts, nil if the consumers need not wait for it
+	quit    chan struct{} // Closed when the component is shutting down
+	abort   chan struct{} // Closed when the shutdown deadline is exceeded
+	wait    chan struct{} // Closed when all consumers have finished
+}
+
+// shard is an event queue consumed by one or more consumer goroutines.
+type shard[T comparable] struct {
+	mutex  sync.Mutex
+	queue  *priorityQueue[T]
+	cond   *sync.Cond
+	closed bool // Whether the component is shutting down
+}
+
+func newShard[T comparable](weights []int) *shard[T] {
+	s := &shard[T]{queue: newPriorityQueue[T](weights)}
+	s.cond = sync.NewCond(&s.mutex)
+	return s
 }
 
 // Init initializes the asyncq component.
 func (c *AsyncqComponent[T]) Init(ctx context.Context) error {
+	options := c.Options()
+	numConsumers := max(options.NumConsumers, 1)
+	numShards := 1
+	switch options.Ordering {
+	case "", "fifo":
+		if numConsumers > 1 {
+			c.Logger().Warn("numConsumers is ignored in fifo ordering mode", "numConsumers", numConsumers)
+		}
+		numConsumers = 1
+	case "unordered":
+	case "keyed":
+		numShards = numConsumers
+	default:
+		return fmt.Errorf("asyncq: unknown ordering %q", options.Ordering)
+	}
+	switch options.Overflow {
+	case "", "drop-newest", "drop-oldest", "block", "callback":
+	default:
+		return fmt.Errorf("asyncq: unknown overflow policy %q", options.Overflow)
+	}
+	for _, w := range options.PriorityWeights {
+		if w <= 0 {
+			return fmt.Errorf("asyncq: priority weights must be positive, got %v", options.PriorityWeights)
+		}
+	}
+
+	var replay []walRecord
+	if options.WAL.Dir != "" {
+		var err error
+		if c.wal, replay, err = openWAL(options.WAL, c.Logger()); err != nil {
+			return err
+		}
+	}
+
 	c.eventSystem = event.NewEventSystem[T](true)
-	c.queue = newQueue[T](128)
+	c.seed = maphash.MakeSeed()
+	c.shards = make([]*shard[T], numShards)
+	for i := range c.shards {
+		c.shards[i] = newShard[T](options.PriorityWeights)
+	}
+	c.timers = newTimerQueue(c.dispatchTimer)
+	c.stats = newStats[T]()
 	c.status = int32(lifecycle.Running)
 	c.quit = make(chan struct{})
+	c.abort = make(chan struct{})
+	c.ctx, c.cancel = context.WithCancel(context.Background())
 	c.wait = make(chan struct{})
-	c.cond = sync.NewCond(&c.mutex)
-	go c.run()
+	if c.wal != nil {
+		// The logged events are queued ahead of the events dispatched by other
+		// components, and dispatched after the listeners are added on start.
+		c.replay(replay)
+		c.started = make(chan struct{})
+	}
+	for i := 0; i < numConsumers; i++ {
+		c.wg.Add(1)
+		go c.run(i, c.shards[i%numShards])
+	}
+	go func() {
+		c.wg.Wait()
+		close(c.wait)
+	}()
+	return nil
+}
+
+// Start registers the HTTP handler and starts dispatching the events queued
+// since Init if the write-ahead log is enabled.
+func (c *AsyncqComponent[T]) Start(ctx context.Context) error {
+	if server := c.Refs().HTTPServer.Component(); server != nil {
+		if root := c.Options().HTTPPath; root != "" {
+			c.Logger().Info("register HTTP handler", "stats", path.Join(root, "/stats"))
+			server.HandleFunc([]string{http.MethodGet}, path.Join(root, "/stats"), c.handleStats)
+		}
+	}
+	if c.started != nil {
+		close(c.started)
+	}
 	return nil
 }
 
-// Uninit shuts down the asyncq component.
+// replay queues the events not acknowledged in the write-ahead log.
+func (c *AsyncqComponent[T]) replay(records []walRecord) {
+	if len(records) == 0 {
+		return
+	}
+	codec := c.getCodec()
+	replayed := 0
+	for _, r := range records {
+		e, err := codec.Decode(r.payload)
+		if err != nil {
+			// The event is kept in the log for the next start.
+			c.Logger().Error("failed to decode event from wal", slog.Uint64("seq", r.seq), slog.Any("error", err))
+			continue
+		}
+		c.mutex.Lock()
+		c.grow()
+		c.mutex.Unlock()
+		front := entry[T]{event: e, seq: r.seq, time: time.Now()}
+		// The shards are not closed before the consumers start.
+		c.push(c.shard(e), front)
+		c.stats.enqueued(e.Typeof())
+		replayed++
+	}
+	c.Logger().Info("asyncq component replayed events from wal", "count", replayed, "total", len(records))
+}
+
+// drainLogInterval is the interval for logging the progress of draining the
+// queue on shutdown.
+const drainLogInterval = 5 * time.Second
+
+// Uninit shuts down the asyncq component. The queued events are dispatched
+// until ctx is done, or ShutdownTimeout elapses if ctx has no deadline. The
+// events left after that are kept in the write-ahead log if enabled, or passed
+// to the dead-letter handler with the context error otherwise, and Uninit
+// returns an error reporting the number of them.
 func (c *AsyncqComponent[T]) Uninit(ctx context.Context) error {
 	if !atomic.CompareAndSwapInt32(&c.status, int32(lifecycle.Running), int32(lifecycle.Stopping)) {
 		c.Logger().Error("asyncq component not running")
 		return ErrClosed
 	}
 	close(c.quit)
-	c.cond.Signal()
+	// Wake up the producers blocked on a full queue.
+	c.mutex.Lock()
+	c.notifySpace()
+	c.mutex.Unlock()
+	c.cleanTimers()
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		s.closed = true
+		s.mutex.Unlock()
+		s.cond.Broadcast()
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		if timeout := time.Duration(c.Options().ShutdownTimeout); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
 	c.Logger().Info("asyncq component waiting for shutdown")
-	<-c.wait
+	err := c.drain(ctx)
 	atomic.StoreInt32(&c.status, int32(lifecycle.Closed))
-	return nil
+	if c.wal != nil {
+		err = errors.Join(err, c.wal.close())
+	}
+	return err
+}
+
+// drain waits for the consumers to dispatch the remaining events until ctx
+// is done, and logs the progress periodically.
+func (c *AsyncqComponent[T]) drain(ctx context.Context) error {
+	defer c.cancel()
+	ticker := time.NewTicker(drainLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.wait:
+			return nil
+		case <-ticker.C:
+			c.mutex.Lock()
+			size := c.size
+			c.mutex.Unlock()
+			c.Logger().Info("asyncq component draining", "remaining", size)
+		case <-ctx.Done():
+			// Stop the consumers after the events being dispatched. They are
+			// not waited for, since a listener may block forever.
+			close(c.abort)
+			c.cancel()
+			remaining := c.takeRemaining()
+			c.Logger().Warn("asyncq component shutdown deadline exceeded", "remaining", len(remaining), "error", ctx.Err())
+			c.handleRemaining(ctx.Err(), remaining)
+			return fmt.Errorf("asyncq: %d events not dispatched: %w", len(remaining), ctx.Err())
+		}
+	}
+}
+
+// takeRemaining removes and returns the events left in the queues.
+func (c *AsyncqComponent[T]) takeRemaining() []entry[T] {
+	var remaining []entry[T]
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		for s.queue.size() > 0 {
+			if front := s.queue.pop(); front.event != nil {
+				remaining = append(remaining, front)
+			}
+		}
+		s.mutex.Unlock()
+	}
+	c.done(len(remaining))
+	return remaining
 }
 
-// run is the main loop for processing events.
-func (c *AsyncqComponent[T]) run() {
+// handleRemaining keeps the events left on shutdown in the write-ahead log,
+// or passes them to the dead-letter handler.
+func (c *AsyncqComponent[T]) handleRemaining(err error, remaining []entry[T]) {
+	if len(remaining) == 0 {
+		return
+	}
+	if c.wal != nil {
+		c.Logger().Info("asyncq component kept undispatched events in wal", "count", len(remaining))
+		return
+	}
+	c.mutex.Lock()
+	handler := c.deadLetterHandler
+	c.mutex.Unlock()
+	if handler == nil {
+		c.Logger().Error("asyncq component discarded undispatched events", "count", len(remaining))
+		return
+	}
+	for _, front := range remaining {
+		c.deadLetter(context.Background(), front.event, err)
+	}
+}
+
+// run is the main loop of a consumer for processing events of the shard.
+func (c *AsyncqComponent[T]) run(id int, s *shard[T]) {
+	defer c.wg.Done()
 	options := c.Options()
-	c.Logger().Info("asyncq component running", "lockThread", options.LockThread)
+	c.Logger().Info("asyncq consumer running", "id", id, "lockThread", options.LockThread)
 	if options.LockThread {
 		runtime.LockOSThread()
 		defer runtime.UnlockOSThread()
 	}
-	ctx := context.Background()
+	if c.started != nil {
+		select {
+		case <-c.started:
+		case <-c.quit:
+		}
+	}
+	ctx := c.ctx
 	for {
-		c.cond.L.Lock()
-		for c.queue.size() == 0 {
-			c.cond.Wait()
+		s.mutex.Lock()
+		for s.queue.size() == 0 && !s.closed {
+			s.cond.Wait()
 		}
-		front := c.queue.pop()
-		size := c.queue.size()
-		c.cond.L.Unlock()
+		if s.closed {
+			s.mutex.Unlock()
+			break
+		}
+		front := s.queue.pop()
+		s.mutex.Unlock()
 
-		if front != nil {
-			c.eventSystem.DispatchEvent(ctx, front)
-			if size > 0 {
-				continue
-			}
+		if front.event != nil {
+			c.consume(ctx, s, front)
 		}
+	}
+
+	c.Logger().Info("asyncq consumer quiting", "id", id)
+	c.clean(s)
+	c.Logger().Info("asyncq consumer cleanup complete", "id", id)
+}
 
+// clean processes remaining events in the shard during shutdown, until the
+// shutdown deadline is exceeded.
+func (c *AsyncqComponent[T]) clean(s *shard[T]) {
+	ctx := c.ctx
+	for {
 		select {
-		case <-c.quit:
-			c.Logger().Info("asyncq component quiting")
-			c.clean()
-			c.Logger().Info("asyncq component cleanup complete")
-			close(c.wait)
+		case <-c.abort:
 			return
 		default:
 		}
+		s.mutex.Lock()
+		if s.queue.size() == 0 {
+			s.mutex.Unlock()
+			break
+		}
+		front := s.queue.pop()
+		s.mutex.Unlock()
+
+		if front.event != nil {
+			c.consume(ctx, s, front)
+		}
 	}
 }
 
-// clean processes remaining events in the queue during shutdown.
-func (c *AsyncqComponent[T]) clean() {
-	c.Logger().Info("asyncq component cleaning up")
-	ctx := context.Background()
-	for {
-		c.cond.L.Lock()
-		if c.queue.size() == 0 {
-			c.cond.L.Unlock()
-			break
+// cleanTimers stops the timer queue, and dispatches or discards the pending
+// delayed events according to DrainTimers.
+func (c *AsyncqComponent[T]) cleanTimers() {
+	pending := c.timers.close()
+	if len(pending) == 0 {
+		return
+	}
+	if !c.Options().DrainTimers {
+		c.Logger().Info("asyncq component discarded pending delayed events", "count", len(pending))
+		return
+	}
+	c.Logger().Info("asyncq component dispatching pending delayed events", "count", len(pending))
+	for _, t := range pending {
+		c.dispatchTimer(t.ctx, t.event)
+	}
+}
+
+// consume dispatches the entry popped from the shard, together with the
+// following events of the same type if the type has batch listeners.
+func (c *AsyncqComponent[T]) consume(ctx context.Context, s *shard[T], front entry[T]) {
+	listeners := c.batchListeners.get(front.event.Typeof())
+	if len(listeners) == 0 {
+		c.dispatch(ctx, front)
+		return
+	}
+	c.dispatchBatch(ctx, c.collect(s, front), listeners)
+}
+
+// dispatch dispatches the entry popped from the queue to the listeners.
+func (c *AsyncqComponent[T]) dispatch(ctx context.Context, front entry[T]) {
+	c.done(1)
+	settled := c.handle(ctx, front.event)
+	c.stats.dispatched(front.event.Typeof(), time.Since(front.time))
+	if settled {
+		c.ack(front)
+	}
+}
+
+// dispatchBatch dispatches each entry of the batch to the listeners, and then
+// the whole batch to the batch listeners.
+func (c *AsyncqComponent[T]) dispatchBatch(ctx context.Context, batch []entry[T], listeners []batchListener[T]) {
+	c.done(len(batch))
+	events := make([]event.Event[T], len(batch))
+	settled := make([]bool, len(batch))
+	for i, front := range batch {
+		events[i] = front.event
+		settled[i] = c.handle(ctx, front.event)
+	}
+	typ := events[0].Typeof()
+	for _, l := range listeners {
+		err := c.retry(typ, func() error {
+			return l.listener.HandleEvents(ctx, events)
+		})
+		if err == nil {
+			continue
+		}
+		if c.keep(typ, len(events), err) {
+			clear(settled)
+			continue
+		}
+		for _, e := range events {
+			c.deadLetter(ctx, e, err)
+		}
+	}
+	for i, front := range batch {
+		c.stats.dispatched(typ, time.Since(front.time))
+		if settled[i] {
+			c.ack(front)
+		}
+	}
+}
+
+// handle dispatches the event to the listeners, and passes it to the
+// dead-letter handler if it still fails after all retries. It reports
+// whether the event is settled, i.e. not kept in the write-ahead log.
+func (c *AsyncqComponent[T]) handle(ctx context.Context, e event.Event[T]) bool {
+	err := c.retry(e.Typeof(), func() error {
+		return c.eventSystem.DispatchEvent(ctx, e)
+	})
+	if err == nil {
+		return true
+	}
+	if c.keep(e.Typeof(), 1, err) {
+		return false
+	}
+	c.deadLetter(ctx, e, err)
+	return true
+}
+
+// keep reports whether the failed events are kept in the write-ahead log to
+// be replayed on the next start, which is the case if the log is enabled and
+// the shutdown deadline is exceeded.
+func (c *AsyncqComponent[T]) keep(typ T, n int, err error) bool {
+	if c.wal == nil {
+		return false
+	}
+	select {
+	case <-c.abort:
+		c.Logger().Warn("asyncq component kept failed events in wal", "type", typ, "count", n, "error", err)
+		return true
+	default:
+		return false
+	}
+}
+
+// retry calls fn, and calls it again with backoff on failure according to
+// the retry options. It stops retrying when the component is shutting down,
+// or when the shutdown deadline is exceeded if the write-ahead log is enabled,
+// and returns the error of the last attempt.
+func (c *AsyncqComponent[T]) retry(typ T, fn func() error) error {
+	options := c.Options().Retry
+	maxBackoff := cmp.Or(time.Duration(options.MaxBackoff), 10*time.Second)
+	backoff := min(cmp.Or(time.Duration(options.Backoff), 100*time.Millisecond), maxBackoff)
+	stop := c.quit
+	if c.wal != nil {
+		// The failed events are not lost until the shutdown deadline is
+		// exceeded, after which they are kept in the log.
+		stop = c.abort
+	}
+	for i := 0; ; i++ {
+		err := callSafely(fn)
+		if err == nil || i >= options.MaxRetries {
+			return err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-stop:
+			t.Stop()
+			return err
+		}
+		backoff = min(backoff*2, maxBackoff)
+		c.stats.retried(typ)
+	}
+}
+
+// callSafely calls fn, and recovers the panic of a listener as an
+// *asyncq.PanicError.
+func callSafely(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &asyncq.PanicError{Value: r, Stack: debug.Stack()}
 		}
-		front := c.queue.pop()
-		c.cond.L.Unlock()
+	}()
+	return fn()
+}
+
+// deadLetter passes the failed event to the dead-letter handler, or logs it
+// if no handler is set.
+func (c *AsyncqComponent[T]) deadLetter(ctx context.Context, e event.Event[T], err error) {
+	c.stats.deadLettered(e.Typeof())
+	c.mutex.Lock()
+	handler := c.deadLetterHandler
+	c.mutex.Unlock()
+	if handler == nil {
+		c.logError("asyncq component discarded failed event", e, err)
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			c.logError("asyncq dead-letter handler panicked", e, &asyncq.PanicError{Value: r, Stack: debug.Stack()})
+		}
+	}()
+	handler(ctx, e, err)
+}
+
+// logError logs the error of the event, with the stack trace if it is a panic.
+func (c *AsyncqComponent[T]) logError(msg string, e event.Event[T], err error) {
+	attrs := []any{slog.Any("type", e.Typeof()), slog.Any("error", err)}
+	if pe, ok := err.(*asyncq.PanicError); ok {
+		attrs = append(attrs, slog.String("stack", string(pe.Stack)))
+	}
+	c.Logger().Error(msg, attrs...)
+}
+
+// shard returns the shard for the event.
+func (c *AsyncqComponent[T]) shard(e event.Event[T]) *shard[T] {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	var h uint64
+	if k, ok := e.(asyncq.KeyedEvent); ok {
+		h = maphash.String(c.seed, k.EventKey())
+	} else {
+		h = c.next.Add(1)
+	}
+	return c.shards[h%uint64(len(c.shards))]
+}
 
-		if front != nil {
-			c.eventSystem.DispatchEvent(ctx, front)
+// priority returns the priority of the event, 0 if not specified.
+func priority[T comparable](e event.Event[T]) int {
+	if p, ok := e.(asyncq.PriorityEvent); ok {
+		return p.EventPriority()
+	}
+	return 0
+}
+
+// done decreases the total number of requests in the queues by n.
+func (c *AsyncqComponent[T]) done(n int) {
+	c.mutex.Lock()
+	c.size -= n
+	c.notifySpace()
+	c.mutex.Unlock()
+}
+
+// notifySpace wakes up the producers waiting for room in the queue.
+// It must be called with c.mutex held.
+func (c *AsyncqComponent[T]) notifySpace() {
+	if c.space != nil {
+		close(c.space)
+		c.space = nil
+	}
+}
+
+// dropOldest removes the oldest request of the lowest priority from the shard,
+// or from any other shard if it is empty. It must be called with c.mutex held.
+func (c *AsyncqComponent[T]) dropOldest(s *shard[T]) entry[T] {
+	for i := -1; i < len(c.shards); i++ {
+		if i >= 0 {
+			s = c.shards[i]
+		}
+		s.mutex.Lock()
+		front := s.queue.dropOldest()
+		s.mutex.Unlock()
+		if front.event != nil {
+			c.size--
+			return front
 		}
 	}
+	return entry[T]{}
+}
+
+// SetOverflowHandler implements asyncq.Component.SetOverflowHandler.
+func (c *AsyncqComponent[T]) SetOverflowHandler(handler asyncq.OverflowHandler[T]) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.overflowHandler = handler
+}
+
+// SetDeadLetterHandler implements asyncq.Component.SetDeadLetterHandler.
+func (c *AsyncqComponent[T]) SetDeadLetterHandler(handler asyncq.DeadLetterHandler[T]) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.deadLetterHandler = handler
+}
+
+// AddBatchListener implements asyncq.Component.AddBatchListener.
+func (c *AsyncqComponent[T]) AddBatchListener(listener asyncq.BatchListener[T]) event.ListenerID {
+	return c.batchListeners.add(listener)
+}
+
+// RemoveBatchListener implements asyncq.Component.RemoveBatchListener.
+func (c *AsyncqComponent[T]) RemoveBatchListener(id event.ListenerID) bool {
+	return c.batchListeners.remove(id)
 }
 
 // AddListener implements the event.Dispatcher interface.
@@ -150,39 +644,194 @@ func (c *AsyncqComponent[T]) DispatchEvent(ctx context.Context, e event.Event[T]
 		c.Logger().Error("asyncq component not running")
 		return ErrClosed
 	}
+	return c.enqueue(ctx, e)
+}
+
+// DispatchAfter implements asyncq.Component.DispatchAfter.
+func (c *AsyncqComponent[T]) DispatchAfter(ctx context.Context, e event.Event[T], d time.Duration) (asyncq.Timer, error) {
+	return c.DispatchAt(ctx, e, time.Now().Add(d))
+}
+
+// DispatchAt implements asyncq.Component.DispatchAt.
+func (c *AsyncqComponent[T]) DispatchAt(ctx context.Context, e event.Event[T], t time.Time) (asyncq.Timer, error) {
+	if atomic.LoadInt32(&c.status) != int32(lifecycle.Running) {
+		c.Logger().Error("asyncq component not running")
+		return nil, ErrClosed
+	}
+	timer := c.timers.add(ctx, e, t)
+	if timer == nil {
+		return nil, ErrClosed
+	}
+	return timer, nil
+}
 
+// dispatchTimer enqueues the delayed event when its timer fires.
+func (c *AsyncqComponent[T]) dispatchTimer(ctx context.Context, e event.Event[T]) {
+	if err := c.enqueue(ctx, e); err != nil {
+		c.Logger().Warn("delayed event discarded", slog.Any("event", e), slog.Any("error", err))
+	}
+}
+
+// enqueue adds the event to the queue according to the overflow policy.
+func (c *AsyncqComponent[T]) enqueue(ctx context.Context, e event.Event[T]) error {
 	options := c.Options()
+	s := c.shard(e)
 	c.mutex.Lock()
-	size := c.queue.size()
-	if options.MaxSize > 0 && size >= options.MaxSize {
-		c.mutex.Unlock()
-		c.Logger().Warn(
-			"event discarded because the queue is full",
-			slog.Int("maxSize", options.MaxSize),
-			slog.Any("event", e),
-		)
-		return ErrFull
+	for options.MaxSize > 0 && c.size >= options.MaxSize {
+		switch options.Overflow {
+		case "drop-oldest":
+			if dropped := c.dropOldest(s); dropped.event != nil {
+				c.ack(dropped)
+				c.stats.dropped(dropped.event.Typeof())
+				c.Logger().Warn(
+					"oldest event discarded because the queue is full",
+					slog.Int("maxSize", options.MaxSize),
+					slog.Any("event", dropped.event),
+				)
+				continue
+			}
+			// All queued requests are being dispatched, so the queue is
+			// allowed to exceed MaxSize by this request.
+		case "block":
+			if c.space == nil {
+				c.space = make(chan struct{})
+			}
+			space := c.space
+			c.mutex.Unlock()
+			select {
+			case <-space:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if atomic.LoadInt32(&c.status) != int32(lifecycle.Running) {
+				return ErrClosed
+			}
+			c.mutex.Lock()
+			continue
+		case "callback":
+			if handler := c.overflowHandler; handler != nil {
+				c.mutex.Unlock()
+				c.stats.overflowed(e.Typeof())
+				return handler(ctx, e)
+			}
+			fallthrough
+		default:
+			c.mutex.Unlock()
+			c.stats.dropped(e.Typeof())
+			c.Logger().Warn(
+				"event discarded because the queue is full",
+				slog.Int("maxSize", options.MaxSize),
+				slog.Any("event", e),
+			)
+			return ErrFull
+		}
+		break
 	}
-
-	c.queue.push(e)
-	size = c.queue.size()
-	oldMaxSizeEver := c.updateMaxSizeEver(size)
+	size, peaked := c.grow()
 	c.mutex.Unlock()
 
+	front := entry[T]{event: e, time: time.Now()}
+	if c.wal != nil {
+		seq, err := c.log(e)
+		if err != nil {
+			c.done(1)
+			if !errors.Is(err, ErrClosed) {
+				c.Logger().Error("failed to write event to wal", slog.Any("event", e), slog.Any("error", err))
+			}
+			return err
+		}
+		front.seq = seq
+	}
+	if err := c.push(s, front); err != nil {
+		c.done(1)
+		c.ack(front)
+		return err
+	}
+	c.stats.enqueued(e.Typeof())
+
 	const warningSizeMask = 1<<15 - 1
-	if size == 1 {
-		c.cond.Signal()
-	} else if size&warningSizeMask == 0 && size > oldMaxSizeEver {
+	if peaked && size&warningSizeMask == 0 {
 		c.Logger().Warn("queue size reached new peak", "size", size)
 	}
 	return nil
 }
 
-// updateMaxSizeEver updates the peak number of requests in the queue.
-func (c *AsyncqComponent[T]) updateMaxSizeEver(size int) int {
-	maxSizeEver := c.maxSizeEver
-	if size > c.maxSizeEver || (size<<1) < c.maxSizeEver {
-		c.maxSizeEver = size
+// log appends the event to the write-ahead log and returns its sequence number.
+func (c *AsyncqComponent[T]) log(e event.Event[T]) (uint64, error) {
+	payload, err := c.getCodec().Encode(e)
+	if err != nil {
+		return 0, err
 	}
-	return maxSizeEver
+	return c.wal.append(payload)
+}
+
+// push adds the entry to the shard. The total size must have been increased.
+func (c *AsyncqComponent[T]) push(s *shard[T], front entry[T]) error {
+	s.mutex.Lock()
+	if s.closed {
+		// The consumers may have finished draining the shard.
+		s.mutex.Unlock()
+		return ErrClosed
+	}
+	s.queue.push(front, priority(front.event))
+	s.mutex.Unlock()
+	s.cond.Signal()
+	return nil
+}
+
+// ack acknowledges the logged entry after it is dispatched or discarded.
+func (c *AsyncqComponent[T]) ack(front entry[T]) {
+	if front.seq != 0 {
+		c.wal.ack(front.seq)
+	}
+}
+
+// getCodec returns the codec of events for the write-ahead log.
+func (c *AsyncqComponent[T]) getCodec() asyncq.Codec[T] {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.codec == nil {
+		return gobCodec[T]{}
+	}
+	return c.codec
+}
+
+// SetCodec implements asyncq.Component.SetCodec.
+func (c *AsyncqComponent[T]) SetCodec(codec asyncq.Codec[T]) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.codec = codec
+}
+
+// grow increases the total number of requests in the queues by one, and
+// returns the new size and whether it reaches a new peak. It must be called
+// with c.mutex held.
+func (c *AsyncqComponent[T]) grow() (int, bool) {
+	c.size++
+	if c.size <= c.peak {
+		return c.size, false
+	}
+	c.peak = c.size
+	return c.size, true
+}
+
+// Stats implements asyncq.Component.Stats.
+func (c *AsyncqComponent[T]) Stats() asyncq.Stats {
+	c.mutex.Lock()
+	out := asyncq.Stats{
+		Depth:     c.size,
+		PeakDepth: c.peak,
+		MaxSize:   c.Options().MaxSize,
+	}
+	c.mutex.Unlock()
+	c.stats.snapshot(&out)
+	return out
+}
+
+// handleStats writes the statistics as JSON.
+func (c *AsyncqComponent[T]) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(c.Stats())
 }
@@ -0,0 +1,666 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopherd/core/component"
+	"github.com/gopherd/core/event"
+	"github.com/gopherd/core/op"
+	"github.com/gopherd/core/typing"
+
+	"github.com/gopherd/components/asyncq"
+)
+
+type mockEntity struct{}
+
+func (mockEntity) GetComponent(uuid string) component.Component {
+	return nil
+}
+
+func (mockEntity) Logger() *slog.Logger {
+	return slog.Default()
+}
+
+func mustNew(t *testing.T, options asyncq.Options) *AsyncqComponent[reflect.Type] {
+	t.Helper()
+	comp, err := component.Create(asyncq.Name)
+	if err != nil {
+		t.Fatalf("Failed to create component %q: %v", asyncq.Name, err)
+	}
+	if err := comp.Setup(mockEntity{}, &component.Config{
+		Name:    asyncq.Name,
+		Options: typing.NewRawObject(op.MustResult(json.Marshal(options))),
+	}, false); err != nil {
+		t.Fatalf("Failed to setup component %q: %v", asyncq.Name, err)
+	}
+	return comp.(*AsyncqComponent[reflect.Type])
+}
+
+// mustInit initializes the component.
+func mustInit(t *testing.T, c *AsyncqComponent[reflect.Type]) {
+	t.Helper()
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+}
+
+// testEvent is an event with an ordering key, a priority and a sequence number.
+type testEvent struct {
+	key      string
+	priority int
+	seq      int
+}
+
+func (e *testEvent) Typeof() reflect.Type {
+	return reflect.TypeOf(e)
+}
+
+func (e *testEvent) EventKey() string {
+	return e.key
+}
+
+func (e *testEvent) EventPriority() int {
+	return e.priority
+}
+
+// recorder records the sequence numbers of handled events by key.
+type recorder struct {
+	mu   sync.Mutex
+	seqs map[string][]int
+}
+
+func (r *recorder) listen(c *AsyncqComponent[reflect.Type]) {
+	r.seqs = make(map[string][]int)
+	c.AddListener(event.Listen(reflect.TypeOf((*testEvent)(nil)), func(ctx context.Context, e *testEvent) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.seqs[e.key] = append(r.seqs[e.key], e.seq)
+		return nil
+	}))
+}
+
+func TestOrdering(t *testing.T) {
+	tests := []struct {
+		name    string
+		options asyncq.Options
+		ordered bool // Whether events of the same key must be handled in order
+	}{
+		{"FIFO", asyncq.Options{Ordering: "fifo", NumConsumers: 4}, true},
+		{"Unordered", asyncq.Options{Ordering: "unordered", NumConsumers: 4}, false},
+		{"Keyed", asyncq.Options{Ordering: "keyed", NumConsumers: 4}, true},
+	}
+
+	const numKeys, numEvents = 8, 1000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mustNew(t, tt.options)
+			mustInit(t, c)
+			var r recorder
+			r.listen(c)
+			for i := 0; i < numEvents; i++ {
+				e := &testEvent{key: strconv.Itoa(i % numKeys), seq: i}
+				if err := c.DispatchEvent(context.Background(), e); err != nil {
+					t.Fatalf("Failed to dispatch event: %v", err)
+				}
+			}
+			if err := c.Uninit(context.Background()); err != nil {
+				t.Fatalf("Failed to uninit component: %v", err)
+			}
+
+			total := 0
+			for key, seqs := range r.seqs {
+				total += len(seqs)
+				for i := 1; tt.ordered && i < len(seqs); i++ {
+					if seqs[i] < seqs[i-1] {
+						t.Errorf("Expected events of key %s in order, but got %d after %d", key, seqs[i], seqs[i-1])
+						break
+					}
+				}
+			}
+			if total != numEvents {
+				t.Errorf("Expected %d events handled, but got %d", numEvents, total)
+			}
+		})
+	}
+}
+
+func TestUnknownOrdering(t *testing.T) {
+	c := mustNew(t, asyncq.Options{Ordering: "random"})
+	if err := c.Init(context.Background()); err == nil {
+		t.Error("Expected an error, but got nil")
+	}
+}
+
+func TestDispatchAfterUninit(t *testing.T) {
+	c := mustNew(t, asyncq.Options{})
+	mustInit(t, c)
+	if err := c.Uninit(context.Background()); err != nil {
+		t.Fatalf("Failed to uninit component: %v", err)
+	}
+	if err := c.DispatchEvent(context.Background(), &testEvent{}); err != ErrClosed {
+		t.Errorf("Expected %v, but got %v", ErrClosed, err)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	q := newQueue[reflect.Type](4)
+	var seq, want int
+	push := func(n int) {
+		for i := 0; i < n; i++ {
+			q.push(entry[reflect.Type]{event: &testEvent{seq: seq}})
+			seq++
+		}
+	}
+	pop := func(n int) {
+		for i := 0; i < n; i++ {
+			e := q.pop().event.(*testEvent)
+			if e.seq != want {
+				t.Fatalf("Expected event %d, but got %d", want, e.seq)
+			}
+			want++
+		}
+	}
+
+	// Wrap around the buffer before expanding.
+	push(3)
+	pop(2)
+	push(10)
+	pop(11)
+	if q.size() != 0 || q.pop().event != nil {
+		t.Errorf("Expected empty queue, but got size %d", q.size())
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	q := newPriorityQueue[reflect.Type]([]int{1, 3})
+	for i := 0; i < 4; i++ {
+		q.push(entry[reflect.Type]{event: &testEvent{priority: 0, seq: i}}, 0)
+		q.push(entry[reflect.Type]{event: &testEvent{priority: 1, seq: i}}, 1)
+	}
+	// Out of range priorities are clamped.
+	q.push(entry[reflect.Type]{event: &testEvent{priority: 1, seq: 4}}, 5)
+
+	var got []int
+	for q.size() > 0 {
+		next := q.peek()
+		front := q.pop()
+		if next.event != front.event {
+			t.Fatalf("Expected peek to return the next entry %v, but got %v", front.event, next.event)
+		}
+		got = append(got, front.event.(*testEvent).priority)
+	}
+	if want := []int{1, 1, 0, 1, 1, 1, 0, 0, 0}; !slices.Equal(got, want) {
+		t.Errorf("Expected priorities %v, but got %v", want, got)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	c := mustNew(t, asyncq.Options{PriorityWeights: []int{1, 100}})
+	mustInit(t, c)
+	r, release := blockFirst(t, c)
+	for i, p := range []int{0, 0, 1} {
+		if err := c.DispatchEvent(context.Background(), &testEvent{priority: p, seq: i + 1}); err != nil {
+			t.Fatalf("Failed to dispatch event: %v", err)
+		}
+	}
+	close(release)
+	if err := c.Uninit(context.Background()); err != nil {
+		t.Fatalf("Failed to uninit component: %v", err)
+	}
+	if got, want := r.seqs[""], []int{0, 3, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("Expected events %v handled, but got %v", want, got)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	tests := []struct {
+		overflow string
+		err      error // Expected error of the overflowing dispatch
+		want     []int // Expected sequence numbers of handled events
+	}{
+		{"drop-newest", ErrFull, []int{0, 1, 2}},
+		{"drop-oldest", nil, []int{0, 2, 3}},
+		{"block", context.DeadlineExceeded, []int{0, 1, 2}},
+		{"callback", errSpilled, []int{0, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.overflow, func(t *testing.T) {
+			c := mustNew(t, asyncq.Options{MaxSize: 2, Overflow: tt.overflow})
+			mustInit(t, c)
+			var spilled []int
+			c.SetOverflowHandler(func(ctx context.Context, e event.Event[reflect.Type]) error {
+				spilled = append(spilled, e.(*testEvent).seq)
+				return errSpilled
+			})
+			r, release := blockFirst(t, c)
+
+			// Fill the queue while the consumer is blocked.
+			for i := 1; i < 3; i++ {
+				if err := c.DispatchEvent(context.Background(), &testEvent{seq: i}); err != nil {
+					t.Fatalf("Failed to dispatch event %d: %v", i, err)
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if err := c.DispatchEvent(ctx, &testEvent{seq: 3}); err != tt.err {
+				t.Errorf("Expected %v, but got %v", tt.err, err)
+			}
+			if tt.overflow == "callback" && !slices.Equal(spilled, []int{3}) {
+				t.Errorf("Expected event 3 spilled, but got %v", spilled)
+			}
+
+			close(release)
+			if err := c.Uninit(context.Background()); err != nil {
+				t.Fatalf("Failed to uninit component: %v", err)
+			}
+			if got := r.seqs[""]; !slices.Equal(got, tt.want) {
+				t.Errorf("Expected events %v handled, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	c := mustNew(t, asyncq.Options{MaxSize: 1, Overflow: "block"})
+	mustInit(t, c)
+	r, release := blockFirst(t, c)
+	if err := c.DispatchEvent(context.Background(), &testEvent{seq: 1}); err != nil {
+		t.Fatalf("Failed to dispatch event 1: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.DispatchEvent(context.Background(), &testEvent{seq: 2})
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected dispatch blocked, but got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Expected nil after the queue is drained, but got %v", err)
+	}
+	if err := c.Uninit(context.Background()); err != nil {
+		t.Fatalf("Failed to uninit component: %v", err)
+	}
+	if got, want := r.seqs[""], []int{0, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("Expected events %v handled, but got %v", want, got)
+	}
+}
+
+var errSpilled = errors.New("spilled")
+
+// blockFirst adds a recorder, dispatches event 0 and waits until the consumer
+// is blocked handling it. The consumer is unblocked when release is closed.
+func blockFirst(t *testing.T, c *AsyncqComponent[reflect.Type]) (r *recorder, release chan struct{}) {
+	t.Helper()
+	r = new(recorder)
+	r.listen(c)
+	release = make(chan struct{})
+	started := make(chan struct{})
+	var once sync.Once
+	c.AddListener(event.Listen(reflect.TypeOf((*testEvent)(nil)), func(ctx context.Context, e *testEvent) error {
+		once.Do(func() {
+			close(started)
+			<-release
+		})
+		return nil
+	}))
+	if err := c.DispatchEvent(context.Background(), &testEvent{seq: 0}); err != nil {
+		t.Fatalf("Failed to dispatch event 0: %v", err)
+	}
+	<-started
+	return r, release
+}
+
+func TestDispatchAfter(t *testing.T) {
+	c := mustNew(t, asyncq.Options{})
+	mustInit(t, c)
+	handled := make(chan int, 4)
+	c.AddListener(event.Listen(reflect.TypeOf((*testEvent)(nil)), func(ctx context.Context, e *testEvent) error {
+		handled <- e.seq
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	for i, d := range []time.Duration{40, 20, 30, 10} {
+		var err error
+		var timer asyncq.Timer
+		switch i {
+		case 2:
+			// Cancelled by the context.
+			timer, err = c.DispatchAfter(ctx, &testEvent{seq: i}, d*time.Millisecond)
+		default:
+			timer, err = c.DispatchAt(context.Background(), &testEvent{seq: i}, start.Add(d*time.Millisecond))
+		}
+		if err != nil {
+			t.Fatalf("Failed to dispatch event %d: %v", i, err)
+		}
+		if i == 1 && !timer.Cancel() {
+			t.Error("Expected timer cancelled, but got false")
+		}
+		if i == 1 && timer.Cancel() {
+			t.Error("Expected cancelled timer not cancelled again, but got true")
+		}
+	}
+	cancel()
+
+	for _, want := range []int{3, 0} {
+		select {
+		case got := <-handled:
+			if got != want {
+				t.Errorf("Expected event %d, but got %d", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Event %d was not dispatched", want)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected events dispatched after 40ms, but got %v", elapsed)
+	}
+	if err := c.Uninit(context.Background()); err != nil {
+		t.Fatalf("Failed to uninit component: %v", err)
+	}
+	if len(handled) != 0 {
+		t.Errorf("Expected cancelled events not dispatched, but got %d", <-handled)
+	}
+}
+
+func TestDrainTimers(t *testing.T) {
+	for _, drain := range []bool{false, true} {
+		t.Run(strconv.FormatBool(drain), func(t *testing.T) {
+			c := mustNew(t, asyncq.Options{DrainTimers: drain})
+			mustInit(t, c)
+			var r recorder
+			r.listen(c)
+			at := time.Now().Add(time.Hour)
+			for i := 0; i < 3; i++ {
+				if _, err := c.DispatchAt(context.Background(), &testEvent{seq: 2 - i}, at.Add(-time.Duration(i))); err != nil {
+					t.Fatalf("Failed to dispatch event: %v", err)
+				}
+			}
+			if err := c.Uninit(context.Background()); err != nil {
+				t.Fatalf("Failed to uninit component: %v", err)
+			}
+			var want []int
+			if drain {
+				want = []int{0, 1, 2}
+			}
+			if got := r.seqs[""]; !slices.Equal(got, want) {
+				t.Errorf("Expected events %v handled, but got %v", want, got)
+			}
+			if _, err := c.DispatchAfter(context.Background(), &testEvent{}, 0); err != ErrClosed {
+				t.Errorf("Expected %v, but got %v", ErrClosed, err)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := mustNew(t, asyncq.Options{MaxSize: 2})
+	mustInit(t, c)
+	_, release := blockFirst(t, c)
+	for i := 1; i < 4; i++ {
+		c.DispatchEvent(context.Background(), &testEvent{seq: i})
+	}
+	stats := c.Stats()
+	if stats.Depth != 2 || stats.PeakDepth != 2 || stats.MaxSize != 2 {
+		t.Errorf("Expected depth 2, peak depth 2 and max size 2, but got %d, %d and %d", stats.Depth, stats.PeakDepth, stats.MaxSize)
+	}
+	if want := (asyncq.Counters{Enqueued: 3, Dropped: 1}); stats.Counters != want {
+		t.Errorf("Expected counters %+v, but got %+v", want, stats.Counters)
+	}
+
+	close(release)
+	if err := c.Uninit(context.Background()); err != nil {
+		t.Fatalf("Failed to uninit component: %v", err)
+	}
+	w := httptest.NewRecorder()
+	c.handleStats(w, httptest.NewRequest(http.MethodGet, "/asyncq/stats", nil))
+	stats = asyncq.Stats{}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.Depth != 0 || stats.PeakDepth != 2 {
+		t.Errorf("Expected depth 0 and peak depth 2, but got %d and %d", stats.Depth, stats.PeakDepth)
+	}
+	ts, ok := stats.Types[fmt.Sprint(reflect.TypeOf((*testEvent)(nil)))]
+	if !ok {
+		t.Fatalf("Expected stats of testEvent, but got %v", stats.Types)
+	}
+	if want := (asyncq.Counters{Enqueued: 3, Dispatched: 3, Dropped: 1}); ts.Counters != want {
+		t.Errorf("Expected counters %+v, but got %+v", want, ts.Counters)
+	}
+	if ts.Latency.Count != 3 || len(ts.Latency.Counts) != len(asyncq.LatencyBounds)+1 {
+		t.Errorf("Expected 3 latency samples in %d buckets, but got %+v", len(asyncq.LatencyBounds)+1, ts.Latency)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	c := mustNew(t, asyncq.Options{})
+	mustInit(t, c)
+	var handled []int
+	c.AddListener(event.Listen(reflect.TypeOf((*testEvent)(nil)), func(ctx context.Context, e *testEvent) error {
+		if e.seq == 0 {
+			panic("boom")
+		}
+		handled = append(handled, e.seq)
+		return nil
+	}))
+	var deadLetters []error
+	c.SetDeadLetterHandler(func(ctx context.Context, e event.Event[reflect.Type], err error) {
+		deadLetters = append(deadLetters, err)
+	})
+	for i := 0; i < 2; i++ {
+		if err := c.DispatchEvent(context.Background(), &testEvent{seq: i}); err != nil {
+			t.Fatalf("Failed to dispatch event: %v", err)
+		}
+	}
+	if err := c.Uninit(context.Background()); err != nil {
+		t.Fatalf("Failed to uninit component: %v", err)
+	}
+	if want := []int{1}; !slices.Equal(handled, want) {
+		t.Errorf("Expected events %v handled, but got %v", want, handled)
+	}
+	if len(deadLetters) != 1 {
+		t.Fatalf("Expected 1 dead letter, but got %v", deadLetters)
+	}
+	var pe *asyncq.PanicError
+	if !errors.As(deadLetters[0], &pe) || pe.Value != "boom" || len(pe.Stack) == 0 {
+		t.Errorf("Expected panic error with value boom, but got %v", deadLetters[0])
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		failures   int  // Number of attempts failing before success
+		dead       bool // Whether the event is dead-lettered
+		counters   asyncq.Counters
+	}{
+		{"Succeeded", 2, 2, false, asyncq.Counters{Enqueued: 1, Dispatched: 1, Retried: 2}},
+		{"Exhausted", 1, 3, true, asyncq.Counters{Enqueued: 1, Dispatched: 1, Retried: 1, DeadLettered: 1}},
+		{"NoRetry", 0, 1, true, asyncq.Counters{Enqueued: 1, Dispatched: 1, DeadLettered: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mustNew(t, asyncq.Options{Retry: asyncq.RetryOptions{
+				MaxRetries: tt.maxRetries,
+				Backoff:    typing.Duration(time.Millisecond),
+			}})
+			mustInit(t, c)
+			attempts := 0
+			c.AddListener(event.Listen(reflect.TypeOf((*testEvent)(nil)), func(ctx context.Context, e *testEvent) error {
+				attempts++
+				if attempts <= tt.failures {
+					return errSpilled
+				}
+				return nil
+			}))
+			var deadLetter error
+			c.SetDeadLetterHandler(func(ctx context.Context, e event.Event[reflect.Type], err error) {
+				deadLetter = err
+			})
+			if err := c.DispatchEvent(context.Background(), &testEvent{}); err != nil {
+				t.Fatalf("Failed to dispatch event: %v", err)
+			}
+			// Wait for the consumer to finish before shutting down, so
+			// that the retries are not cut short.
+			for c.Stats().Dispatched == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			if err := c.Uninit(context.Background()); err != nil {
+				t.Fatalf("Failed to uninit component: %v", err)
+			}
+			if want := min(tt.failures, tt.maxRetries) + 1; attempts != want {
+				t.Errorf("Expected %d attempts, but got %d", want, attempts)
+			}
+			if dead := deadLetter != nil; dead != tt.dead {
+				t.Errorf("Expected dead-lettered %v, but got %v", tt.dead, deadLetter)
+			}
+			if got := c.Stats().Counters; got != tt.counters {
+				t.Errorf("Expected counters %+v, but got %+v", tt.counters, got)
+			}
+		})
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration // ShutdownTimeout option
+		ctx     time.Duration // Deadline of the Uninit context, none if 0
+	}{
+		{"Context", 0, 50 * time.Millisecond},
+		{"ShutdownTimeout", 50 * time.Millisecond, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mustNew(t, asyncq.Options{ShutdownTimeout: typing.Duration(tt.timeout)})
+			mustInit(t, c)
+			var (
+				mu   sync.Mutex
+				dead []int
+			)
+			c.SetDeadLetterHandler(func(ctx context.Context, e event.Event[reflect.Type], err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if err != context.DeadlineExceeded {
+					t.Errorf("Expected %v, but got %v", context.DeadlineExceeded, err)
+				}
+				dead = append(dead, e.(*testEvent).seq)
+			})
+			_, release := blockFirst(t, c)
+			defer close(release)
+			for i := 1; i < 3; i++ {
+				if err := c.DispatchEvent(context.Background(), &testEvent{seq: i}); err != nil {
+					t.Fatalf("Failed to dispatch event %d: %v", i, err)
+				}
+			}
+
+			ctx := context.Background()
+			if tt.ctx > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctx)
+				defer cancel()
+			}
+			if err := c.Uninit(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected %v, but got %v", context.DeadlineExceeded, err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if want := []int{1, 2}; !slices.Equal(dead, want) {
+				t.Errorf("Expected events %v dead-lettered, but got %v", want, dead)
+			}
+			if depth := c.Stats().Depth; depth != 0 {
+				t.Errorf("Expected depth 0, but got %d", depth)
+			}
+		})
+	}
+}
+
+// batchRecorder records the batches of persistentEvent.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (r *batchRecorder) listen(c *AsyncqComponent[reflect.Type]) event.ListenerID {
+	return c.AddBatchListener(asyncq.ListenBatch(reflect.TypeOf((*persistentEvent)(nil)), func(ctx context.Context, events []*persistentEvent) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		var seqs []int
+		for _, e := range events {
+			seqs = append(seqs, e.Seq)
+		}
+		r.batches = append(r.batches, seqs)
+		return nil
+	}))
+}
+
+func TestBatchListener(t *testing.T) {
+	c := mustNew(t, asyncq.Options{BatchSize: 3})
+	mustInit(t, c)
+	var handled []int
+	c.AddListener(event.Listen(reflect.TypeOf((*persistentEvent)(nil)), func(ctx context.Context, e *persistentEvent) error {
+		handled = append(handled, e.Seq)
+		return nil
+	}))
+	var r batchRecorder
+	r.listen(c)
+	removed := r.listen(c)
+	if !c.RemoveBatchListener(removed) || c.RemoveBatchListener(removed) {
+		t.Errorf("Expected batch listener removed only once")
+	}
+
+	_, release := blockFirst(t, c)
+	for _, seq := range []int{1, 2, 3, 4} {
+		c.DispatchEvent(context.Background(), &persistentEvent{Seq: seq})
+	}
+	c.DispatchEvent(context.Background(), &testEvent{seq: 5})
+	c.DispatchEvent(context.Background(), &persistentEvent{Seq: 6})
+	close(release)
+	if err := c.Uninit(context.Background()); err != nil {
+		t.Fatalf("Failed to uninit component: %v", err)
+	}
+	if want := [][]int{{1, 2, 3}, {4}, {6}}; !reflect.DeepEqual(r.batches, want) {
+		t.Errorf("Expected batches %v, but got %v", want, r.batches)
+	}
+	if want := []int{1, 2, 3, 4, 6}; !slices.Equal(handled, want) {
+		t.Errorf("Expected events %v handled, but got %v", want, handled)
+	}
+}
+
+func TestBatchLinger(t *testing.T) {
+	c := mustNew(t, asyncq.Options{BatchLinger: typing.Duration(time.Second)})
+	mustInit(t, c)
+	var r batchRecorder
+	r.listen(c)
+	for seq := 1; seq <= 2; seq++ {
+		c.DispatchEvent(context.Background(), &persistentEvent{Seq: seq})
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Shutting down ends the linger window.
+	if err := c.Uninit(context.Background()); err != nil {
+		t.Fatalf("Failed to uninit component: %v", err)
+	}
+	if want := [][]int{{1, 2}}; !reflect.DeepEqual(r.batches, want) {
+		t.Errorf("Expected batches %v, but got %v", want, r.batches)
+	}
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gopherd/core/event"
+
+	"github.com/gopherd/components/asyncq"
+)
+
+// batchListener represents a registered batch listener.
+type batchListener[T comparable] struct {
+	id       event.ListenerID
+	listener asyncq.BatchListener[T]
+}
+
+// batchListeners holds the batch listeners by event type. The slices of
+// listeners are never modified in place, so they can be used without the lock.
+type batchListeners[T comparable] struct {
+	mutex     sync.RWMutex
+	nextID    event.ListenerID
+	listeners map[T][]batchListener[T]
+}
+
+// add adds the listener and returns its ID.
+func (b *batchListeners[T]) add(listener asyncq.BatchListener[T]) event.ListenerID {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.listeners == nil {
+		b.listeners = make(map[T][]batchListener[T])
+	}
+	b.nextID++
+	typ := listener.EventType()
+	b.listeners[typ] = append(slices.Clip(b.listeners[typ]), batchListener[T]{id: b.nextID, listener: listener})
+	return b.nextID
+}
+
+// remove removes the listener by ID. It returns false if not found.
+func (b *batchListeners[T]) remove(id event.ListenerID) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for typ, listeners := range b.listeners {
+		i := slices.IndexFunc(listeners, func(l batchListener[T]) bool { return l.id == id })
+		if i < 0 {
+			continue
+		}
+		if len(listeners) == 1 {
+			delete(b.listeners, typ)
+		} else {
+			b.listeners[typ] = slices.Delete(slices.Clone(listeners), i, i+1)
+		}
+		return true
+	}
+	return false
+}
+
+// get returns the listeners of the event type.
+func (b *batchListeners[T]) get(typ T) []batchListener[T] {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.listeners[typ]
+}
+
+// collect pops the events following front in the shard while they have the
+// same type as front, until the batch is full. If the shard is empty, it waits
+// for more events for up to the linger window.
+func (c *AsyncqComponent[T]) collect(s *shard[T], front entry[T]) []entry[T] {
+	options := c.Options()
+	size := cmp.Or(options.BatchSize, 100)
+	linger := time.Duration(options.BatchLinger)
+	typ := front.event.Typeof()
+	batch := []entry[T]{front}
+
+	expired := linger <= 0
+	var timer *time.Timer
+	s.mutex.Lock()
+	for len(batch) < size {
+		for s.queue.size() == 0 && !s.closed && !expired {
+			if timer == nil {
+				timer = time.AfterFunc(linger, func() {
+					s.mutex.Lock()
+					expired = true
+					s.mutex.Unlock()
+					s.cond.Broadcast()
+				})
+			}
+			s.cond.Wait()
+		}
+		if next := s.queue.peek(); next.event == nil || next.event.Typeof() != typ {
+			break
+		}
+		batch = append(batch, s.queue.pop())
+	}
+	s.mutex.Unlock()
+	if timer != nil {
+		timer.Stop()
+	}
+	return batch
+}
@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/gopherd/core/event"
+)
+
+// gobCodec implements asyncq.Codec with encoding/gob. The event is encoded as
+// an interface value, so its concrete type must be registered by event.Register.
+type gobCodec[T comparable] struct{}
+
+// Encode implements asyncq.Codec.Encode.
+func (gobCodec[T]) Encode(e event.Event[T]) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&e); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode implements asyncq.Codec.Decode.
+func (gobCodec[T]) Decode(data []byte) (event.Event[T], error) {
+	var e event.Event[T]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
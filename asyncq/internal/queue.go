@@ -1,13 +1,22 @@
 package internal
 
 import (
+	"time"
+
 	"github.com/gopherd/core/event"
 	"github.com/gopherd/core/math/mathutil"
 )
 
+// entry represents a queued event.
+type entry[T comparable] struct {
+	event event.Event[T]
+	seq   uint64    // Sequence number in the write-ahead log, 0 if not logged
+	time  time.Time // Time the event was enqueued
+}
+
 // queue represents an internal event queue with circular buffer implementation.
 type queue[T comparable] struct {
-	buf []event.Event[T]
+	buf []entry[T]
 	len int
 	cap int
 	pos int
@@ -19,7 +28,7 @@ type queue[T comparable] struct {
 func newQueue[T comparable](cap int) *queue[T] {
 	cap = int(mathutil.UpperPow2(cap))
 	return &queue[T]{
-		buf: make([]event.Event[T], cap),
+		buf: make([]entry[T], cap),
 		cap: cap,
 	}
 }
@@ -29,9 +38,9 @@ func (q *queue[T]) size() int {
 	return q.len
 }
 
-// push adds an Event to the queue and returns the new size.
+// push adds an entry to the queue and returns the new size.
 // If the queue is full, it will expand automatically.
-func (q *queue[T]) push(e event.Event[T]) int {
+func (q *queue[T]) push(e entry[T]) int {
 	if q.len == q.cap {
 		q.expand()
 	}
@@ -41,20 +50,29 @@ func (q *queue[T]) push(e event.Event[T]) int {
 	return q.len
 }
 
-// pop removes and returns the oldest Event from the queue.
-// If the queue is empty, it returns nil.
-func (q *queue[T]) pop() event.Event[T] {
+// pop removes and returns the oldest entry from the queue.
+// If the queue is empty, it returns a zero entry.
+func (q *queue[T]) pop() entry[T] {
 	if q.len == 0 {
-		return nil
+		return entry[T]{}
 	}
 	idx := q.index(q.pos)
 	v := q.buf[idx]
-	q.buf[idx] = nil // Allow GC to reclaim the memory
+	q.buf[idx] = entry[T]{} // Allow GC to reclaim the memory
 	q.pos++
 	q.len--
 	return v
 }
 
+// front returns the oldest entry without removing it.
+// If the queue is empty, it returns a zero entry.
+func (q *queue[T]) front() entry[T] {
+	if q.len == 0 {
+		return entry[T]{}
+	}
+	return q.buf[q.index(q.pos)]
+}
+
 // index calculates the actual index in the circular buffer.
 func (q *queue[T]) index(n int) int {
 	return n & (q.cap - 1)
@@ -63,17 +81,111 @@ func (q *queue[T]) index(n int) int {
 // expand doubles the capacity of the queue.
 func (q *queue[T]) expand() {
 	oldCap := q.cap
-	newBuf := make([]event.Event[T], oldCap*2)
+	newBuf := make([]entry[T], oldCap*2)
 
-	if q.cur > q.pos {
-		copy(newBuf, q.buf[q.pos:q.cur])
-	} else {
-		n := copy(newBuf, q.buf[q.pos:])
-		copy(newBuf[n:], q.buf[:q.cur])
-	}
+	// The queue is full, so the events start at pos and wrap around the buffer.
+	pos := q.index(q.pos)
+	n := copy(newBuf, q.buf[pos:])
+	copy(newBuf[n:], q.buf[:pos])
 
 	q.buf = newBuf
 	q.cap = len(newBuf)
 	q.pos = 0
 	q.cur = q.len
 }
+
+// priorityQueue represents an event queue with weighted priority lanes.
+// Events of the same lane are popped in FIFO order, and lanes are drained
+// with smooth weighted round-robin so that low lanes are never starved.
+type priorityQueue[T comparable] struct {
+	lanes   []*queue[T] // Lanes from the lowest priority to the highest
+	weights []int       // Weight of each lane
+	current []int       // Current credit of each lane
+	len     int
+}
+
+// newPriorityQueue creates a new priority queue with a lane for each weight.
+// A single lane is created if weights is empty.
+func newPriorityQueue[T comparable](weights []int) *priorityQueue[T] {
+	if len(weights) == 0 {
+		weights = []int{1}
+	}
+	q := &priorityQueue[T]{
+		lanes:   make([]*queue[T], len(weights)),
+		weights: weights,
+		current: make([]int, len(weights)),
+	}
+	for i := range q.lanes {
+		q.lanes[i] = newQueue[T](128)
+	}
+	return q
+}
+
+// size returns the current number of events in all lanes.
+func (q *priorityQueue[T]) size() int {
+	return q.len
+}
+
+// push adds an entry to the lane of the given priority and returns the new size.
+// The priority is clamped to the range of lanes.
+func (q *priorityQueue[T]) push(e entry[T], priority int) int {
+	priority = min(max(priority, 0), len(q.lanes)-1)
+	q.lanes[priority].push(e)
+	q.len++
+	return q.len
+}
+
+// pop removes and returns the oldest entry of the lane chosen by weighted
+// round-robin among the non-empty lanes, preferring higher lanes on ties.
+// If the queue is empty, it returns a zero entry.
+func (q *priorityQueue[T]) pop() entry[T] {
+	best := q.next()
+	if best < 0 {
+		return entry[T]{}
+	}
+	for i, lane := range q.lanes {
+		if lane.size() > 0 {
+			q.current[i] += q.weights[i]
+			q.current[best] -= q.weights[i]
+		}
+	}
+	q.len--
+	return q.lanes[best].pop()
+}
+
+// peek returns the entry to be popped next without removing it.
+// If the queue is empty, it returns a zero entry.
+func (q *priorityQueue[T]) peek() entry[T] {
+	best := q.next()
+	if best < 0 {
+		return entry[T]{}
+	}
+	return q.lanes[best].front()
+}
+
+// next returns the lane to be popped next by weighted round-robin, or -1 if
+// the queue is empty.
+func (q *priorityQueue[T]) next() int {
+	best := -1
+	for i, lane := range q.lanes {
+		if lane.size() == 0 {
+			continue
+		}
+		if best < 0 || q.current[i]+q.weights[i] >= q.current[best]+q.weights[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// dropOldest removes and returns the oldest entry of the lowest non-empty lane.
+// If the queue is empty, it returns a zero entry.
+func (q *priorityQueue[T]) dropOldest() entry[T] {
+	for _, lane := range q.lanes {
+		if lane.size() > 0 {
+			q.len--
+			return lane.pop()
+		}
+	}
+	return entry[T]{}
+}
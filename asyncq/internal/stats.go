@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopherd/components/asyncq"
+)
+
+// typeStats represents the statistics of an event type.
+type typeStats struct {
+	asyncq.Counters
+	latency asyncq.Histogram
+}
+
+// stats collects the event statistics of the queue.
+type stats[T comparable] struct {
+	mutex sync.Mutex
+	total asyncq.Counters
+	types map[T]*typeStats
+}
+
+func newStats[T comparable]() *stats[T] {
+	return &stats[T]{types: make(map[T]*typeStats)}
+}
+
+// get returns the statistics of the event type.
+// It must be called with s.mutex held.
+func (s *stats[T]) get(typ T) *typeStats {
+	ts, ok := s.types[typ]
+	if !ok {
+		ts = &typeStats{latency: asyncq.Histogram{Counts: make([]int64, len(asyncq.LatencyBounds)+1)}}
+		s.types[typ] = ts
+	}
+	return ts
+}
+
+// add applies fn to the counters of all events and of the event type.
+func (s *stats[T]) add(typ T, fn func(*asyncq.Counters)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fn(&s.total)
+	fn(&s.get(typ).Counters)
+}
+
+func (s *stats[T]) enqueued(typ T) {
+	s.add(typ, func(c *asyncq.Counters) { c.Enqueued++ })
+}
+
+func (s *stats[T]) dropped(typ T) {
+	s.add(typ, func(c *asyncq.Counters) { c.Dropped++ })
+}
+
+func (s *stats[T]) overflowed(typ T) {
+	s.add(typ, func(c *asyncq.Counters) { c.Overflowed++ })
+}
+
+func (s *stats[T]) retried(typ T) {
+	s.add(typ, func(c *asyncq.Counters) { c.Retried++ })
+}
+
+func (s *stats[T]) deadLettered(typ T) {
+	s.add(typ, func(c *asyncq.Counters) { c.DeadLettered++ })
+}
+
+// dispatched counts the dispatched event with its latency.
+func (s *stats[T]) dispatched(typ T, latency time.Duration) {
+	i := sort.Search(len(asyncq.LatencyBounds), func(i int) bool {
+		return latency <= asyncq.LatencyBounds[i]
+	})
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.total.Dispatched++
+	ts := s.get(typ)
+	ts.Dispatched++
+	ts.latency.Counts[i]++
+	ts.latency.Count++
+	ts.latency.Sum += latency
+}
+
+// snapshot fills the counters of the statistics.
+func (s *stats[T]) snapshot(out *asyncq.Stats) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	out.Counters = s.total
+	out.Types = make(map[string]asyncq.TypeStats, len(s.types))
+	for typ, ts := range s.types {
+		latency := ts.latency
+		latency.Counts = append([]int64(nil), latency.Counts...)
+		out.Types[fmt.Sprint(typ)] = asyncq.TypeStats{Counters: ts.Counters, Latency: latency}
+	}
+}
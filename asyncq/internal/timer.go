@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gopherd/core/container/heap"
+	"github.com/gopherd/core/event"
+)
+
+// timer represents a delayed event scheduled in a timerQueue.
+type timer[T comparable] struct {
+	queue *timerQueue[T]
+	ctx   context.Context // Context for dispatching the event
+	event event.Event[T]
+	when  time.Time
+	seq   uint64 // Scheduling order for timers with the same time
+	index int    // Index in the heap, -1 if fired or cancelled
+	stop  func() bool
+}
+
+// Cancel implements asyncq.Timer.Cancel.
+func (t *timer[T]) Cancel() bool {
+	return t.queue.remove(t)
+}
+
+// timerHeap implements heap.Interface for timers ordered by time.
+type timerHeap[T comparable] []*timer[T]
+
+func (h timerHeap[T]) Len() int { return len(h) }
+
+func (h timerHeap[T]) Less(i, j int) bool {
+	if h[i].when.Equal(h[j].when) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].when.Before(h[j].when)
+}
+
+func (h timerHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap[T]) Push(t *timer[T]) {
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap[T]) Pop() *timer[T] {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil // Allow GC to reclaim the memory
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
+
+// timerQueue feeds delayed events to the dispatch function when they are due.
+type timerQueue[T comparable] struct {
+	dispatch func(ctx context.Context, e event.Event[T])
+
+	mutex  sync.Mutex
+	heap   timerHeap[T]
+	seq    uint64
+	closed bool
+
+	wakeup chan struct{} // Notifies the loop that the earliest timer has changed
+	quit   chan struct{} // Closed to stop the loop
+	done   chan struct{} // Closed when the loop has stopped
+}
+
+// newTimerQueue creates a timer queue and starts its loop.
+func newTimerQueue[T comparable](dispatch func(ctx context.Context, e event.Event[T])) *timerQueue[T] {
+	q := &timerQueue[T]{
+		dispatch: dispatch,
+		wakeup:   make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// add schedules the event at the given time. The timer is cancelled when ctx
+// is done before it fires. It returns nil if the queue is closed.
+func (q *timerQueue[T]) add(ctx context.Context, e event.Event[T], when time.Time) *timer[T] {
+	t := &timer[T]{queue: q, ctx: context.WithoutCancel(ctx), event: e, when: when}
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return nil
+	}
+	q.seq++
+	t.seq = q.seq
+	heap.Push[*timer[T]](&q.heap, t)
+	earliest := t.index == 0
+	q.mutex.Unlock()
+
+	if ctx.Done() != nil {
+		stop := context.AfterFunc(ctx, func() { q.remove(t) })
+		q.mutex.Lock()
+		if t.index >= 0 {
+			t.stop = stop
+		} else {
+			stop()
+		}
+		q.mutex.Unlock()
+	}
+	if earliest {
+		select {
+		case q.wakeup <- struct{}{}:
+		default:
+		}
+	}
+	return t
+}
+
+// remove removes the timer from the queue. It returns false if the timer has
+// already fired or been removed.
+func (q *timerQueue[T]) remove(t *timer[T]) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove[*timer[T]](&q.heap, t.index)
+	t.release()
+	return true
+}
+
+// release stops watching the context of the fired or removed timer.
+// It must be called with q.mutex held.
+func (t *timer[T]) release() {
+	if t.stop != nil {
+		t.stop()
+		t.stop = nil
+	}
+}
+
+// close stops the loop and returns the pending timers in time order.
+func (q *timerQueue[T]) close() []*timer[T] {
+	q.mutex.Lock()
+	q.closed = true
+	q.mutex.Unlock()
+	close(q.quit)
+	<-q.done
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	pending := make([]*timer[T], 0, len(q.heap))
+	for len(q.heap) > 0 {
+		t := heap.Pop[*timer[T]](&q.heap)
+		t.release()
+		pending = append(pending, t)
+	}
+	return pending
+}
+
+// run fires the due timers until the queue is closed.
+func (q *timerQueue[T]) run() {
+	defer close(q.done)
+	// Stale ticks of tm only cause an extra check of the heap.
+	tm := time.NewTimer(time.Hour)
+	tm.Stop()
+	defer tm.Stop()
+	for {
+		now := time.Now()
+		var due []*timer[T]
+		q.mutex.Lock()
+		for len(q.heap) > 0 && !q.heap[0].when.After(now) {
+			t := heap.Pop[*timer[T]](&q.heap)
+			t.release()
+			due = append(due, t)
+		}
+		wait := time.Duration(-1)
+		if len(q.heap) > 0 {
+			wait = q.heap[0].when.Sub(now)
+		}
+		q.mutex.Unlock()
+
+		for _, t := range due {
+			q.dispatch(t.ctx, t.event)
+		}
+		if len(due) > 0 {
+			continue
+		}
+
+		var timeout <-chan time.Time
+		if wait >= 0 {
+			tm.Reset(wait)
+			timeout = tm.C
+		}
+		select {
+		case <-timeout:
+		case <-q.wakeup:
+			tm.Stop()
+		case <-q.quit:
+			return
+		}
+	}
+}
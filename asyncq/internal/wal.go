@@ -0,0 +1,379 @@
+package internal
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopherd/components/asyncq"
+)
+
+// Record types of the write-ahead log.
+const (
+	walEvent byte = 1 // An enqueued event
+	walAck   byte = 2 // An acknowledged event
+)
+
+// walHeaderSize is the size of the record header: body length and CRC32 of the body.
+// The body consists of the record type, the sequence number and the payload.
+const walHeaderSize = 8
+
+// walSegmentExt is the file extension of the log segments.
+const walSegmentExt = ".wal"
+
+// errInvalidRecord is returned when a log record is truncated or corrupted.
+var errInvalidRecord = errors.New("asyncq: invalid write-ahead log record")
+
+// walRecord represents an event record of the write-ahead log.
+type walRecord struct {
+	seq     uint64
+	payload []byte
+}
+
+// walSegment represents a segment file of the write-ahead log.
+type walSegment struct {
+	id      uint64
+	first   uint64 // Sequence number of the first event in the segment
+	pending int    // Number of events not acknowledged yet
+}
+
+// wal is a segmented write-ahead log of the queued events. Events are
+// appended to the active segment, and acknowledged by appending ack records.
+// Segments are removed in order once all of their events are acknowledged,
+// so the ack records of the remaining events are never lost.
+type wal struct {
+	dir         string
+	segmentSize int64
+	sync        string
+	logger      *slog.Logger
+
+	mutex    sync.Mutex
+	segments []*walSegment // Segments in order, the last one is active
+	file     *os.File      // Active segment file
+	size     int64         // Size of the active segment file
+	nextSeq  uint64        // Sequence number of the next event
+	dirty    bool          // Whether the active segment has unsynced writes
+	closed   bool          // Whether the log is closed
+
+	quit, done chan struct{} // Channels for stopping the sync loop
+}
+
+// openWAL opens the write-ahead log in the directory. The events not
+// acknowledged in the existing segments are moved to a new active segment
+// and returned in order.
+func openWAL(options asyncq.WALOptions, logger *slog.Logger) (*wal, []walRecord, error) {
+	switch options.Sync {
+	case "", "always", "interval", "none":
+	default:
+		return nil, nil, fmt.Errorf("asyncq: unknown wal sync policy %q", options.Sync)
+	}
+	if err := os.MkdirAll(options.Dir, 0755); err != nil {
+		return nil, nil, err
+	}
+	w := &wal{
+		dir:         options.Dir,
+		segmentSize: int64(options.SegmentSize),
+		sync:        options.Sync,
+		logger:      logger,
+	}
+	if w.segmentSize <= 0 {
+		w.segmentSize = 64 << 20
+	}
+
+	ids, err := w.segmentIDs()
+	if err != nil {
+		return nil, nil, err
+	}
+	records, maxSeq, err := w.load(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Sequence numbers are not reused in case an old segment is not removed.
+	w.nextSeq = maxSeq + 1
+	var nextID uint64 = 1
+	if len(ids) > 0 {
+		nextID = ids[len(ids)-1] + 1
+	}
+	if err := w.createSegment(nextID); err != nil {
+		return nil, nil, err
+	}
+
+	// Move the pending events to the new segment so that the old
+	// segments, including their ack records, can be removed.
+	for i := range records {
+		seq, err := w.write(walEvent, 0, records[i].payload)
+		if err != nil {
+			w.file.Close()
+			return nil, nil, err
+		}
+		records[i].seq = seq
+	}
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return nil, nil, err
+	}
+	for _, id := range ids {
+		if err := os.Remove(w.segmentPath(id)); err != nil {
+			logger.Warn("failed to remove wal segment", "id", id, "error", err)
+		}
+	}
+
+	if w.sync == "" || w.sync == "interval" {
+		interval := time.Duration(options.SyncInterval)
+		if interval <= 0 {
+			interval = time.Second
+		}
+		w.quit = make(chan struct{})
+		w.done = make(chan struct{})
+		go w.syncLoop(interval)
+	}
+	return w, records, nil
+}
+
+// segmentIDs returns the IDs of the existing segments in order.
+func (w *wal) segmentIDs() ([]uint64, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), walSegmentExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if id, err := strconv.ParseUint(name, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// load reads the segments and returns the events not acknowledged in order,
+// and the maximum sequence number in the segments.
+func (w *wal) load(ids []uint64) (records []walRecord, maxSeq uint64, err error) {
+	acked := make(map[uint64]bool)
+	for _, id := range ids {
+		f, err := os.Open(w.segmentPath(id))
+		if err != nil {
+			return nil, 0, err
+		}
+		err = readRecords(f, func(typ byte, seq uint64, payload []byte) {
+			maxSeq = max(maxSeq, seq)
+			switch typ {
+			case walEvent:
+				records = append(records, walRecord{seq: seq, payload: payload})
+			case walAck:
+				acked[seq] = true
+			}
+		})
+		f.Close()
+		if errors.Is(err, errInvalidRecord) {
+			// A torn write at the tail of a segment after a crash.
+			w.logger.Warn("ignoring the rest of wal segment", "id", id, "error", err)
+		} else if err != nil {
+			return nil, 0, err
+		}
+	}
+	records = slices.DeleteFunc(records, func(r walRecord) bool {
+		return acked[r.seq]
+	})
+	slices.SortFunc(records, func(a, b walRecord) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	return records, maxSeq, nil
+}
+
+// readRecords reads the records from r until EOF.
+func readRecords(r io.Reader, fn func(typ byte, seq uint64, payload []byte)) error {
+	var header [walHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if err == io.ErrUnexpectedEOF {
+				return errInvalidRecord
+			}
+			return err
+		}
+		n := binary.BigEndian.Uint32(header[:4])
+		if n < 9 || n > 1<<30 {
+			return errInvalidRecord
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(r, body); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return errInvalidRecord
+			}
+			return err
+		}
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:]) {
+			return errInvalidRecord
+		}
+		fn(body[0], binary.BigEndian.Uint64(body[1:9]), body[9:])
+	}
+}
+
+func (w *wal) segmentPath(id uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d%s", id, walSegmentExt))
+}
+
+// createSegment creates a new active segment.
+// It must be called with w.mutex held after the previous one is closed.
+func (w *wal) createSegment(id uint64) error {
+	f, err := os.OpenFile(w.segmentPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.size = 0
+	w.segments = append(w.segments, &walSegment{id: id, first: w.nextSeq})
+	return nil
+}
+
+// write appends a record to the active segment. For event records, a new
+// sequence number is allocated and returned.
+// It must be called with w.mutex held.
+func (w *wal) write(typ byte, seq uint64, payload []byte) (uint64, error) {
+	if w.size >= w.segmentSize {
+		if err := w.roll(); err != nil {
+			return 0, err
+		}
+	}
+	if typ == walEvent {
+		seq = w.nextSeq
+	}
+	buf := make([]byte, walHeaderSize+9+len(payload))
+	body := buf[walHeaderSize:]
+	body[0] = typ
+	binary.BigEndian.PutUint64(body[1:9], seq)
+	copy(body[9:], payload)
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(body))
+	if _, err := w.file.Write(buf); err != nil {
+		return 0, err
+	}
+	w.size += int64(len(buf))
+	w.dirty = true
+	if w.sync == "always" {
+		if err := w.file.Sync(); err != nil {
+			return 0, err
+		}
+		w.dirty = false
+	}
+	if typ == walEvent {
+		w.nextSeq++
+		w.segments[len(w.segments)-1].pending++
+	}
+	return seq, nil
+}
+
+// roll closes the active segment and creates a new one.
+// It must be called with w.mutex held.
+func (w *wal) roll() error {
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.dirty = false
+	return w.createSegment(w.segments[len(w.segments)-1].id + 1)
+}
+
+// append appends an event record and returns its sequence number. It returns
+// ErrClosed after the log is closed.
+func (w *wal) append(payload []byte) (uint64, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	return w.write(walEvent, 0, payload)
+}
+
+// ack acknowledges the event and removes the segments in which all events
+// are acknowledged. It does nothing after the log is closed.
+func (w *wal) ack(seq uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		// The event is replayed on the next start.
+		return
+	}
+	if _, err := w.write(walAck, seq, nil); err != nil {
+		w.logger.Warn("failed to write wal ack record", "seq", seq, "error", err)
+	}
+	i := sort.Search(len(w.segments), func(i int) bool {
+		return w.segments[i].first > seq
+	}) - 1
+	if i < 0 {
+		return
+	}
+	w.segments[i].pending--
+	// The active segment is never removed here.
+	for len(w.segments) > 1 && w.segments[0].pending == 0 {
+		if err := os.Remove(w.segmentPath(w.segments[0].id)); err != nil {
+			w.logger.Warn("failed to remove wal segment", "id", w.segments[0].id, "error", err)
+		}
+		w.segments = w.segments[1:]
+	}
+}
+
+// syncLoop syncs the active segment periodically.
+func (w *wal) syncLoop(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mutex.Lock()
+			if w.dirty {
+				if err := w.file.Sync(); err != nil {
+					w.logger.Warn("failed to sync wal segment", "error", err)
+				}
+				w.dirty = false
+			}
+			w.mutex.Unlock()
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// close syncs and closes the active segment. All segments are removed if
+// all events are acknowledged.
+func (w *wal) close() error {
+	if w.quit != nil {
+		close(w.quit)
+		<-w.done
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+	err := errors.Join(w.file.Sync(), w.file.Close())
+	for _, s := range w.segments {
+		if s.pending > 0 {
+			return err
+		}
+	}
+	for _, s := range w.segments {
+		err = errors.Join(err, os.Remove(w.segmentPath(s.id)))
+	}
+	return err
+}
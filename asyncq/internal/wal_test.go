@@ -0,0 +1,270 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gopherd/core/event"
+	"github.com/gopherd/core/typing"
+
+	"github.com/gopherd/components/asyncq"
+)
+
+// persistentEvent is an event encodable by the default codec.
+type persistentEvent struct {
+	Seq int
+}
+
+func (e *persistentEvent) Typeof() reflect.Type {
+	return reflect.TypeOf(e)
+}
+
+func init() {
+	event.Register[reflect.Type](&persistentEvent{})
+}
+
+// mustOpenWAL opens the write-ahead log in dir and returns the replayed records.
+func mustOpenWAL(t *testing.T, options asyncq.WALOptions) (*wal, []walRecord) {
+	t.Helper()
+	w, records, err := openWAL(options, slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open wal: %v", err)
+	}
+	return w, records
+}
+
+// crash closes the write-ahead log files without removing any segment.
+func (w *wal) crash() {
+	if w.quit != nil {
+		close(w.quit)
+		<-w.done
+	}
+	w.file.Close()
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestWALReplay(t *testing.T) {
+	options := asyncq.WALOptions{Dir: t.TempDir(), SegmentSize: 64, Sync: "always"}
+	w, records := mustOpenWAL(t, options)
+	if len(records) != 0 {
+		t.Fatalf("Expected no records, but got %d", len(records))
+	}
+	var seqs []uint64
+	for _, payload := range []string{"a", "b", "c", "d"} {
+		seq, err := w.append([]byte(payload))
+		if err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+		seqs = append(seqs, seq)
+	}
+	w.ack(seqs[0])
+	w.ack(seqs[2])
+	w.crash()
+
+	// Append a torn record to the last segment.
+	files := segmentFiles(t, options.Dir)
+	f, err := os.OpenFile(filepath.Join(options.Dir, files[len(files)-1]), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Failed to open segment: %v", err)
+	}
+	f.Write([]byte{0, 0, 0, 20, 1, 2})
+	f.Close()
+
+	w, records = mustOpenWAL(t, options)
+	var got []string
+	for _, r := range records {
+		got = append(got, string(r.payload))
+	}
+	if want := []string{"b", "d"}; !slices.Equal(got, want) {
+		t.Errorf("Expected records %v, but got %v", want, got)
+	}
+	if files := segmentFiles(t, options.Dir); len(files) != 1 {
+		t.Errorf("Expected old segments removed, but got %v", files)
+	}
+
+	for _, r := range records {
+		w.ack(r.seq)
+	}
+	if err := w.close(); err != nil {
+		t.Fatalf("Failed to close wal: %v", err)
+	}
+	if files := segmentFiles(t, options.Dir); len(files) != 0 {
+		t.Errorf("Expected all segments removed, but got %v", files)
+	}
+}
+
+func TestWALAppendClosed(t *testing.T) {
+	w, _ := mustOpenWAL(t, asyncq.WALOptions{Dir: t.TempDir()})
+	if err := w.close(); err != nil {
+		t.Fatalf("Failed to close wal: %v", err)
+	}
+	if _, err := w.append([]byte("event")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, but got %v", err)
+	}
+}
+
+func TestWALSegments(t *testing.T) {
+	options := asyncq.WALOptions{Dir: t.TempDir(), SegmentSize: 1, Sync: "none"}
+	w, _ := mustOpenWAL(t, options)
+	defer w.close()
+	var seqs []uint64
+	for i := 0; i < 3; i++ {
+		seq, err := w.append([]byte("event"))
+		if err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+		seqs = append(seqs, seq)
+	}
+	if files := segmentFiles(t, options.Dir); len(files) != 3 {
+		t.Fatalf("Expected 3 segments, but got %v", files)
+	}
+
+	// Each ack record starts a new segment, and segments are removed in order.
+	w.ack(seqs[1])
+	if files := segmentFiles(t, options.Dir); len(files) != 4 {
+		t.Errorf("Expected 4 segments, but got %v", files)
+	}
+	w.ack(seqs[0])
+	if files := segmentFiles(t, options.Dir); len(files) != 3 {
+		t.Errorf("Expected 3 segments, but got %v", files)
+	}
+}
+
+func TestWALComponent(t *testing.T) {
+	options := asyncq.Options{WAL: asyncq.WALOptions{Dir: t.TempDir()}}
+	w, _ := mustOpenWAL(t, options.WAL)
+	for i := 0; i < 3; i++ {
+		payload, err := gobCodec[reflect.Type]{}.Encode(&persistentEvent{Seq: i})
+		if err != nil {
+			t.Fatalf("Failed to encode event: %v", err)
+		}
+		if _, err := w.append(payload); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+	w.crash()
+
+	c := mustNew(t, options)
+	mustInit(t, c)
+	// Events dispatched before start, e.g. by other components on init, are
+	// handled after the logged ones.
+	if err := c.DispatchEvent(context.Background(), &persistentEvent{Seq: 3}); err != nil {
+		t.Fatalf("Failed to dispatch event: %v", err)
+	}
+	var got []int
+	c.AddListener(event.Listen(reflect.TypeOf((*persistentEvent)(nil)), func(ctx context.Context, e *persistentEvent) error {
+		got = append(got, e.Seq)
+		return nil
+	}))
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start component: %v", err)
+	}
+	if err := c.Uninit(context.Background()); err != nil {
+		t.Fatalf("Failed to uninit component: %v", err)
+	}
+	if want := []int{0, 1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("Expected events %v handled, but got %v", want, got)
+	}
+	if files := segmentFiles(t, options.WAL.Dir); len(files) != 0 {
+		t.Errorf("Expected all segments removed, but got %v", files)
+	}
+}
+
+func TestWALShutdownDeadline(t *testing.T) {
+	options := asyncq.Options{WAL: asyncq.WALOptions{Dir: t.TempDir()}}
+	c := mustNew(t, options)
+	mustInit(t, c)
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	c.AddListener(event.Listen(reflect.TypeOf((*persistentEvent)(nil)), func(ctx context.Context, e *persistentEvent) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}))
+	for i := 0; i < 3; i++ {
+		if err := c.DispatchEvent(context.Background(), &persistentEvent{Seq: i}); err != nil {
+			t.Fatalf("Failed to dispatch event: %v", err)
+		}
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start component: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Uninit(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+
+	// The event being dispatched and the remaining ones are replayed.
+	w, records := mustOpenWAL(t, options.WAL)
+	defer w.close()
+	if len(records) != 3 {
+		t.Errorf("Expected 3 records, but got %d", len(records))
+	}
+}
+
+func TestWALShutdownRetry(t *testing.T) {
+	options := asyncq.Options{
+		WAL:   asyncq.WALOptions{Dir: t.TempDir()},
+		Retry: asyncq.RetryOptions{MaxRetries: 1000, Backoff: typing.Duration(10 * time.Millisecond)},
+	}
+	c := mustNew(t, options)
+	mustInit(t, c)
+	failed := make(chan struct{}, 1)
+	c.AddListener(event.Listen(reflect.TypeOf((*persistentEvent)(nil)), func(ctx context.Context, e *persistentEvent) error {
+		select {
+		case failed <- struct{}{}:
+		default:
+		}
+		return errors.New("transient failure")
+	}))
+	var deadLettered atomic.Int32
+	c.SetDeadLetterHandler(func(ctx context.Context, e event.Event[reflect.Type], err error) {
+		deadLettered.Add(1)
+	})
+	if err := c.DispatchEvent(context.Background(), &persistentEvent{Seq: 1}); err != nil {
+		t.Fatalf("Failed to dispatch event: %v", err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start component: %v", err)
+	}
+	<-failed
+
+	// The event is retried on shutdown, and kept in the log after the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.Uninit(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+	if n := deadLettered.Load(); n != 0 {
+		t.Errorf("Expected no dead-lettered events, but got %d", n)
+	}
+	w, records := mustOpenWAL(t, options.WAL)
+	defer w.close()
+	if len(records) != 1 {
+		t.Errorf("Expected 1 record, but got %d", len(records))
+	}
+}
@@ -0,0 +1,66 @@
+package asyncq
+
+import "time"
+
+// LatencyBounds are the upper bounds of the dispatch latency histogram buckets.
+// It must not be modified.
+var LatencyBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Stats represents the statistics of the queue.
+type Stats struct {
+	// Depth is the number of events currently in the queue.
+	Depth int
+	// PeakDepth is the highest number of events in the queue since the component started.
+	PeakDepth int
+	// MaxSize is the maximum number of events allowed in the queue.
+	MaxSize int
+	// Counters of all events.
+	Counters
+	// Types contains the statistics by event type, keyed by the formatted type.
+	Types map[string]TypeStats
+}
+
+// Counters represents the event counters of the queue.
+type Counters struct {
+	// Enqueued is the number of events added to the queue.
+	Enqueued int64
+	// Dispatched is the number of events dispatched to the listeners.
+	Dispatched int64
+	// Dropped is the number of events discarded because the queue was full.
+	Dropped int64
+	// Overflowed is the number of events passed to the overflow handler.
+	Overflowed int64
+	// Retried is the number of times events were dispatched again after failures.
+	Retried int64
+	// DeadLettered is the number of events that still failed after all retries.
+	DeadLettered int64
+}
+
+// TypeStats represents the statistics of an event type.
+type TypeStats struct {
+	Counters
+	// Latency is the histogram of the time from enqueue to the end of dispatch.
+	Latency Histogram
+}
+
+// Histogram represents a latency histogram with the buckets of LatencyBounds.
+type Histogram struct {
+	// Counts contains the number of samples in each bucket. Counts[i] is the
+	// number of samples not greater than LatencyBounds[i], and the last one is
+	// the number of samples greater than all bounds.
+	Counts []int64
+	// Count is the total number of samples.
+	Count int64
+	// Sum is the sum of all samples.
+	Sum time.Duration
+}
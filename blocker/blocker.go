@@ -0,0 +1,7 @@
+package blocker
+
+import "context"
+
+// ReloadHook is run when a signal with the "reload" action is received, e.g.
+// to reload the configuration or reopen the log files.
+type ReloadHook func(ctx context.Context) error
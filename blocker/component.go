@@ -2,8 +2,10 @@
 
 package blocker
 
+import "github.com/gopherd/core/typing"
 import "github.com/gopherd/core/op"
 
+var _ = (*typing.Duration)(nil)
 var _ = op.SetDefault[any]
 
 // Name represents the blocker component name.
@@ -11,7 +13,48 @@ const Name = "github.com/gopherd/components/blocker";
 
 type Options struct {
 	HTTPPath string
+	// Signals specifies the signals to handle and their actions. If empty,
+	// SIGINT and SIGTERM stop the process, and SIGHUP runs the reload hooks.
+	// Platforms other than unix and windows only support SIGINT.
+	Signals []SignalOptions
+	// GracePeriod is the time allowed for the process to shut down after a stop
+	// signal. When it expires, or when a second stop signal is received, the
+	// goroutine stacks are logged and the process exits with ExitCode. There is
+	// no time limit if 0. The logged phase is the last lifecycle step of the
+	// blocker itself, so it only tells whether the other components are shutting
+	// down or being uninitialized; the stacks show which one is stuck.
+	GracePeriod typing.Duration
+	// ExitCode is the exit code of the process forced to exit.
+	ExitCode int
 }
 
 func (x *Options) OnLoaded() {
+	op.SetDefault(&x.ExitCode, 3)
+}
+
+// SignalOptions represents the action for a signal.
+type SignalOptions struct {
+	// Signal is the signal name, e.g. "SIGTERM" or "TERM". SIGKILL and SIGSTOP
+	// cannot be caught and are rejected.
+	Signal string
+	// Action specifies what to do when the signal is received.
+	// Supported values:
+	//   - "stop": the blocker returns from Start so that the process shuts down
+	//   - "reload": the hooks added by AddReloadHook are run
+	//   - "ignore": the signal is ignored
+	Action string
+}
+
+func (x *SignalOptions) OnLoaded() {
+	op.SetDefault(&x.Action, "stop")
+}
+
+// Component represents the blocker component API.
+type Component interface {
+	// AddReloadHook adds a hook run when a signal with the "reload" action is received.
+	// Hooks are run in the order they are added, and their errors are logged.
+	AddReloadHook(hook ReloadHook)
+	// Disarm stops the watchdog started after a stop signal, and stops handling
+	// the signals. It should be called once the shutdown completes.
+	Disarm()
 }
@@ -1,13 +1,18 @@
 package internal
 
 import (
+	"cmp"
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"path"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gopherd/core/component"
@@ -22,21 +27,59 @@ func init() {
 	})
 }
 
+// Ensure BlockerComponent implements blocker.Component interface.
+var _ blocker.Component = (*BlockerComponent)(nil)
+
 // BlockerComponent implements the component.Component interface to block
 // process exit on specific signals.
 type BlockerComponent struct {
 	component.BaseComponentWithRefs[blocker.Options, struct {
 		HTTPServer component.OptionalReference[httpserver.Component]
 	}]
-	signals []os.Signal
+	signals []os.Signal        // Signals to be notified
+	ignored []os.Signal        // Signals to be ignored
+	reloads map[os.Signal]bool // Signals with the "reload" action
 	sigChan chan os.Signal
 	wg      sync.WaitGroup
+	exit    func(code int) // Exits the process, os.Exit if not testing
+
+	stopped  time.Time     // Time when the blocker stopped
+	watching atomic.Bool   // Whether the watchdog is running
+	phase    atomic.Value  // Last lifecycle step of the blocker reported by the watchdog
+	disarm   chan struct{} // Closed to stop the watchdog
+	disarmed sync.Once     // Closes disarm once
+
+	mu    sync.Mutex
+	hooks []blocker.ReloadHook
 }
 
 // Init initializes the blockexitComponent.
 func (c *BlockerComponent) Init(ctx context.Context) error {
-	c.signals = []os.Signal{os.Interrupt, os.Kill}
+	options := c.Options().Signals
+	if len(options) == 0 {
+		options = defaultSignals
+	}
+	c.reloads = make(map[os.Signal]bool)
+	for _, o := range options {
+		sig, err := parseSignal(o.Signal)
+		if err != nil {
+			return err
+		}
+		switch o.Action {
+		case "", "stop":
+			c.signals = append(c.signals, sig)
+		case "reload":
+			c.signals = append(c.signals, sig)
+			c.reloads[sig] = true
+		case "ignore":
+			c.ignored = append(c.ignored, sig)
+		default:
+			return fmt.Errorf("blocker: unknown action %q for signal %s", o.Action, o.Signal)
+		}
+	}
 	c.sigChan = make(chan os.Signal, 1)
+	c.disarm = make(chan struct{})
+	c.exit = os.Exit
 	if server := c.Refs().HTTPServer.Component(); server != nil {
 		httpPath := c.Options().HTTPPath
 		if httpPath == "" {
@@ -47,38 +90,172 @@ func (c *BlockerComponent) Init(ctx context.Context) error {
 		}
 		server.HandleFunc([]string{"POST"}, path.Join(httpPath, "stop"), c.stopHandler)
 		server.HandleFunc([]string{"POST"}, path.Join(httpPath, "kill"), c.killHandler)
+		server.HandleFunc([]string{"POST"}, path.Join(httpPath, "reload"), c.reloadHandler)
+	}
+	return nil
+}
+
+// parseSignal returns the signal by name, e.g. "SIGTERM" or "term".
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if name == "SIGKILL" || name == "SIGSTOP" {
+		return nil, fmt.Errorf("blocker: signal %s cannot be caught", name)
 	}
+	sig, ok := signals[name]
+	if !ok {
+		return nil, fmt.Errorf("blocker: unsupported signal %q", name)
+	}
+	return sig, nil
+}
+
+// Shutdown records the shutdown phase for the watchdog.
+func (c *BlockerComponent) Shutdown(ctx context.Context) error {
+	c.phase.Store("shutdown")
 	return nil
 }
 
-// Uninit performs cleanup for the blockexitComponent.
+// Uninit performs cleanup for the blockexitComponent. The signals are still
+// handled by the watchdog if it is running, since the other components are
+// usually uninitialized after the blocker.
 func (c *BlockerComponent) Uninit(ctx context.Context) error {
-	close(c.sigChan)
+	c.phase.Store("uninit")
+	if c.watching.Load() {
+		return nil
+	}
+	c.stopSignals()
 	return nil
 }
 
-// Start begins listening for signals and blocks until a signal is received
-// or the context is cancelled.
+// stopSignals stops handling the signals.
+func (c *BlockerComponent) stopSignals() {
+	signal.Stop(c.sigChan)
+	if len(c.ignored) > 0 {
+		signal.Reset(c.ignored...)
+	}
+}
+
+// Disarm implements blocker.Component interface.
+func (c *BlockerComponent) Disarm() {
+	c.disarmed.Do(func() {
+		close(c.disarm)
+	})
+}
+
+// AddReloadHook implements blocker.Component interface.
+func (c *BlockerComponent) AddReloadHook(hook blocker.ReloadHook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks = append(c.hooks, hook)
+}
+
+// Start begins listening for signals and blocks until a signal with the
+// "stop" action is received or the context is cancelled. Then it starts the
+// watchdog for the rest of the process lifetime.
 func (c *BlockerComponent) Start(ctx context.Context) error {
+	if len(c.ignored) > 0 {
+		signal.Ignore(c.ignored...)
+	}
 	signal.Notify(c.sigChan, c.signals...)
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
-		select {
-		case sig := <-c.sigChan:
-			c.Logger().Info("Received signal", "signal", sig.String())
-		case <-ctx.Done():
-			c.Logger().Info("Context cancelled")
+		for {
+			select {
+			case sig := <-c.sigChan:
+				if c.reloads[sig] {
+					c.Logger().Info("Received reload signal", "signal", sig.String())
+					c.reload(ctx)
+					continue
+				}
+				c.Logger().Info("Received signal", "signal", sig.String())
+			case <-ctx.Done():
+				c.Logger().Info("Context cancelled")
+			}
+			return
 		}
 	}()
 	c.wg.Wait()
+	c.stopped = time.Now()
+	c.phase.Store("start")
+	c.watching.Store(true)
+	go c.watchdog()
 	return nil
 }
 
+// watchdog forces the process to exit when the grace period expires or
+// another stop signal is received, until it is disarmed.
+func (c *BlockerComponent) watchdog() {
+	defer c.stopSignals()
+	defer c.watching.Store(false)
+	var timeout <-chan time.Time
+	if d := time.Duration(c.Options().GracePeriod); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+	for {
+		select {
+		case sig := <-c.sigChan:
+			if c.reloads[sig] {
+				c.Logger().Info("Ignored reload signal during shutdown", "signal", sig.String())
+				continue
+			}
+			c.forceExit("Received second signal", "signal", sig.String())
+		case <-timeout:
+			c.forceExit("Grace period expired", "gracePeriod", time.Duration(c.Options().GracePeriod))
+		case <-c.disarm:
+		}
+		return
+	}
+}
+
+// forceExit logs the stuck phase and the goroutine stacks, and exits the process.
+func (c *BlockerComponent) forceExit(msg string, args ...any) {
+	args = append(args, "phase", c.phase.Load(), "elapsed", time.Since(c.stopped))
+	c.Logger().Error(msg+", forcing exit", args...)
+	c.Logger().Error("Goroutine stacks", "stacks", string(stacks()))
+	c.exit(cmp.Or(c.Options().ExitCode, 3))
+}
+
+// stacks returns the stack traces of all goroutines.
+func stacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// reload runs the reload hooks in order and logs their errors.
+func (c *BlockerComponent) reload(ctx context.Context) {
+	c.mu.Lock()
+	hooks := c.hooks
+	c.mu.Unlock()
+	for i, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			c.Logger().Error("Reload hook failed", "index", i, "error", err)
+		}
+	}
+	c.Logger().Info("Reload hooks completed", "count", len(hooks))
+}
+
+// stopRequest is sent by the stop handler, so that the blocker stops
+// regardless of the action configured for os.Interrupt.
+type stopRequest struct{}
+
+func (stopRequest) String() string { return "stop request" }
+func (stopRequest) Signal()        {}
+
 func (c *BlockerComponent) stopHandler(w http.ResponseWriter, r *http.Request) {
 	c.Logger().Info("Received stop request")
 	select {
-	case c.sigChan <- os.Interrupt:
+	case c.sigChan <- stopRequest{}:
 		io.WriteString(w, "OK")
 	case <-time.After(5 * time.Second):
 		io.WriteString(w, "Timeout")
@@ -94,3 +271,9 @@ func (c *BlockerComponent) killHandler(w http.ResponseWriter, r *http.Request) {
 		io.WriteString(w, "Timeout")
 	}
 }
+
+func (c *BlockerComponent) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	c.Logger().Info("Received reload request")
+	c.reload(r.Context())
+	io.WriteString(w, "OK")
+}
@@ -0,0 +1,194 @@
+//go:build unix || windows
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gopherd/core/component"
+	"github.com/gopherd/core/op"
+	"github.com/gopherd/core/typing"
+
+	"github.com/gopherd/components/blocker"
+)
+
+type mockEntity struct{}
+
+func (mockEntity) GetComponent(uuid string) component.Component {
+	return nil
+}
+
+func (mockEntity) Logger() *slog.Logger {
+	return slog.Default()
+}
+
+func mustNew(t *testing.T, options blocker.Options) *BlockerComponent {
+	t.Helper()
+	comp, err := component.Create(blocker.Name)
+	if err != nil {
+		t.Fatalf("Failed to create component %q: %v", blocker.Name, err)
+	}
+	if err := comp.Setup(mockEntity{}, &component.Config{
+		Name:    blocker.Name,
+		Options: typing.NewRawObject(op.MustResult(json.Marshal(options))),
+	}, false); err != nil {
+		t.Fatalf("Failed to setup component %q: %v", blocker.Name, err)
+	}
+	return comp.(*BlockerComponent)
+}
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		name string
+		sig  os.Signal // Expected signal, nil if an error is expected
+	}{
+		{"SIGTERM", syscall.SIGTERM},
+		{"term", syscall.SIGTERM},
+		{"HUP", syscall.SIGHUP},
+		{"SIGKILL", nil},
+		{"kill", nil},
+		{"SIGFOO", nil},
+	}
+	for _, tt := range tests {
+		sig, err := parseSignal(tt.name)
+		if tt.sig == nil {
+			if err == nil {
+				t.Errorf("Expected error for signal %q, but got %v", tt.name, sig)
+			}
+		} else if err != nil || sig != tt.sig {
+			t.Errorf("Expected signal %v for %q, but got %v and %v", tt.sig, tt.name, sig, err)
+		}
+	}
+}
+
+func TestUnknownAction(t *testing.T) {
+	c := mustNew(t, blocker.Options{Signals: []blocker.SignalOptions{{Signal: "SIGTERM", Action: "restart"}}})
+	if err := c.Init(context.Background()); err == nil {
+		t.Errorf("Expected error for unknown action, but got nil")
+	}
+}
+
+func TestSignals(t *testing.T) {
+	c := mustNew(t, blocker.Options{})
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	reloaded := make(chan struct{}, 1)
+	c.AddReloadHook(func(ctx context.Context) error {
+		reloaded <- struct{}{}
+		return nil
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start(context.Background())
+	}()
+
+	c.sigChan <- syscall.SIGHUP
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("Expected reload hook run on SIGHUP")
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Expected blocker still running after SIGHUP, but got %v", err)
+	default:
+	}
+
+	c.sigChan <- syscall.SIGTERM
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected blocker stopped on SIGTERM")
+	}
+	if err := c.Uninit(context.Background()); err != nil {
+		t.Fatalf("Failed to uninit component: %v", err)
+	}
+	c.Disarm()
+}
+
+func TestDisarm(t *testing.T) {
+	c := mustNew(t, blocker.Options{})
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	exited := make(chan int, 1)
+	c.exit = func(code int) {
+		exited <- code
+	}
+	c.sigChan <- syscall.SIGTERM
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start component: %v", err)
+	}
+	c.Shutdown(context.Background())
+	c.Uninit(context.Background())
+	c.Disarm()
+
+	deadline := time.Now().Add(time.Second)
+	for c.watching.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the watchdog stopped after Disarm")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// A stop signal after the shutdown does not force the process to exit.
+	c.sigChan <- syscall.SIGINT
+	select {
+	case code := <-exited:
+		t.Errorf("Unexpected exit with code %d", code)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	tests := []struct {
+		name    string
+		options blocker.Options
+		signal  bool // Whether a second stop signal is sent
+		code    int  // Expected exit code
+	}{
+		{"GracePeriod", blocker.Options{GracePeriod: typing.Duration(20 * time.Millisecond)}, false, 3},
+		{"SecondSignal", blocker.Options{ExitCode: 5}, true, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mustNew(t, tt.options)
+			if err := c.Init(context.Background()); err != nil {
+				t.Fatalf("Failed to init component: %v", err)
+			}
+			exited := make(chan int, 1)
+			c.exit = func(code int) {
+				exited <- code
+			}
+			c.sigChan <- syscall.SIGTERM
+			if err := c.Start(context.Background()); err != nil {
+				t.Fatalf("Failed to start component: %v", err)
+			}
+			c.Shutdown(context.Background())
+			c.Uninit(context.Background())
+
+			// Reload signals do not force the process to exit.
+			c.sigChan <- syscall.SIGHUP
+			if tt.signal {
+				c.sigChan <- syscall.SIGINT
+			}
+			select {
+			case code := <-exited:
+				if code != tt.code {
+					t.Errorf("Expected exit code %d, but got %d", tt.code, code)
+				}
+				if phase := c.phase.Load(); phase != "uninit" {
+					t.Errorf("Expected phase uninit, but got %v", phase)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Expected the process forced to exit")
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !unix && !windows
+
+package internal
+
+import (
+	"os"
+
+	"github.com/gopherd/components/blocker"
+)
+
+// signals maps the supported signal names to the signals. Only the interrupt
+// signal is portable to all platforms.
+var signals = map[string]os.Signal{
+	"SIGINT": os.Interrupt,
+}
+
+// defaultSignals are the signals handled if none is configured.
+var defaultSignals = []blocker.SignalOptions{
+	{Signal: "SIGINT", Action: "stop"},
+}
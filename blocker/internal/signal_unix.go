@@ -0,0 +1,31 @@
+//go:build unix
+
+package internal
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/gopherd/components/blocker"
+)
+
+// signals maps the supported signal names to the signals.
+var signals = map[string]os.Signal{
+	"SIGINT":   syscall.SIGINT,
+	"SIGTERM":  syscall.SIGTERM,
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGQUIT":  syscall.SIGQUIT,
+	"SIGUSR1":  syscall.SIGUSR1,
+	"SIGUSR2":  syscall.SIGUSR2,
+	"SIGWINCH": syscall.SIGWINCH,
+	"SIGPIPE":  syscall.SIGPIPE,
+	"SIGKILL":  syscall.SIGKILL,
+	"SIGSTOP":  syscall.SIGSTOP,
+}
+
+// defaultSignals are the signals handled if none is configured.
+var defaultSignals = []blocker.SignalOptions{
+	{Signal: "SIGINT", Action: "stop"},
+	{Signal: "SIGTERM", Action: "stop"},
+	{Signal: "SIGHUP", Action: "reload"},
+}
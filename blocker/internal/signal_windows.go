@@ -0,0 +1,26 @@
+//go:build windows
+
+package internal
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/gopherd/components/blocker"
+)
+
+// signals maps the supported signal names to the signals.
+var signals = map[string]os.Signal{
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+// defaultSignals are the signals handled if none is configured.
+var defaultSignals = []blocker.SignalOptions{
+	{Signal: "SIGINT", Action: "stop"},
+	{Signal: "SIGTERM", Action: "stop"},
+	{Signal: "SIGHUP", Action: "reload"},
+}
@@ -12,9 +12,15 @@ const Name = "github.com/gopherd/components/syncq";
 // Options defines the configuration options for the syncq component.
 type Options struct {
 	// Unordered determines if listeners should be invoked in the order they are added.
+	// Listeners are invoked by priority in either case, see syncq.PriorityListener.
 	Unordered bool
 	// Concurrent determines if listeners should be invoked concurrently.
+	// In concurrent mode, all listeners of an event run in parallel and their
+	// errors are joined, and the component is safe for use by multiple goroutines.
 	Concurrent bool
+	// MaxConcurrency is the maximum number of listeners of an event running at the
+	// same time in concurrent mode. There is no limit if 0.
+	MaxConcurrency int
 }
 
 func (x *Options) OnLoaded() {
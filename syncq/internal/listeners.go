@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"slices"
+
+	"github.com/gopherd/core/event"
+
+	"github.com/gopherd/components/syncq"
+)
+
+// typedListener is implemented by listeners and request handlers.
+type typedListener[T comparable] interface {
+	EventType() T
+}
+
+// listener represents a registered listener.
+type listener[T comparable, L typedListener[T]] struct {
+	id       event.ListenerID
+	priority int
+	listener L
+}
+
+// priority returns the priority of the listener, 0 if not specified.
+func priority(l any) int {
+	if p, ok := l.(syncq.PriorityListener); ok {
+		return p.ListenerPriority()
+	}
+	return 0
+}
+
+// listenerSet holds the listeners by event type, sorted by priority from the
+// highest to the lowest. The slices of listeners are
+// never modified in place, so that a slice got by get can be used while
+// listeners are added or removed, e.g. by the listeners themselves.
+type listenerSet[T comparable, L typedListener[T]] struct {
+	nextID    event.ListenerID
+	ordered   bool
+	listeners map[T][]listener[T, L]
+	mapping   map[event.ListenerID]T
+}
+
+func newListenerSet[T comparable, L typedListener[T]](ordered bool) *listenerSet[T, L] {
+	return &listenerSet[T, L]{
+		ordered:   ordered,
+		listeners: make(map[T][]listener[T, L]),
+		mapping:   make(map[event.ListenerID]T),
+	}
+}
+
+// add adds the listener and returns its ID.
+func (s *listenerSet[T, L]) add(l L) event.ListenerID {
+	s.nextID++
+	id := s.nextID
+	eventType := l.EventType()
+	p := priority(l)
+	listeners := s.listeners[eventType]
+	// Listeners of the same priority are kept in the order they are added.
+	i, _ := slices.BinarySearchFunc(listeners, p, func(l listener[T, L], p int) int {
+		if l.priority >= p {
+			return -1
+		}
+		return 1
+	})
+	s.listeners[eventType] = slices.Insert(slices.Clip(listeners), i, listener[T, L]{id: id, priority: p, listener: l})
+	s.mapping[id] = eventType
+	return id
+}
+
+// remove removes the listener by ID. It returns false if not found.
+func (s *listenerSet[T, L]) remove(id event.ListenerID) bool {
+	eventType, ok := s.mapping[id]
+	if !ok {
+		return false
+	}
+	delete(s.mapping, id)
+	listeners := s.listeners[eventType]
+	if len(listeners) == 1 {
+		delete(s.listeners, eventType)
+		return true
+	}
+	i := slices.IndexFunc(listeners, func(l listener[T, L]) bool { return l.id == id })
+	listeners = slices.Clone(listeners)
+	if s.ordered {
+		listeners = slices.Delete(listeners, i, i+1)
+	} else {
+		// Move the last listener of the same priority to keep the order of priorities.
+		last := i
+		for last+1 < len(listeners) && listeners[last+1].priority == listeners[i].priority {
+			last++
+		}
+		listeners[i] = listeners[last]
+		listeners = slices.Delete(listeners, last, last+1)
+	}
+	s.listeners[eventType] = listeners
+	return true
+}
+
+// has reports whether the listener exists.
+func (s *listenerSet[T, L]) has(id event.ListenerID) bool {
+	_, ok := s.mapping[id]
+	return ok
+}
+
+// get returns the listeners of the event type.
+func (s *listenerSet[T, L]) get(eventType T) []listener[T, L] {
+	return s.listeners[eventType]
+}
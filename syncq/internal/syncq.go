@@ -2,6 +2,8 @@ package internal
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"sync"
 
@@ -16,13 +18,16 @@ func init() {
 	})
 }
 
-// Ensure syncqComponent implements event.Dispatcher interface.
-var _ event.EventSystem[reflect.Type] = (*SyncqComponent[reflect.Type])(nil)
+// Ensure SyncqComponent implements syncq.Component interface.
+var _ syncq.Component[reflect.Type] = (*SyncqComponent[reflect.Type])(nil)
 
 // SyncqComponent is a component template that provides a flexible event handling system.
 type SyncqComponent[T comparable] struct {
 	component.BaseComponent[syncq.Options]
-	eventSystem event.EventSystem[T]
+	listeners   *listenerSet[T, event.Listener[T]]
+	handlers    *listenerSet[T, syncq.RequestHandler[T]]
+	middlewares []syncq.Middleware[T]
+	handler     syncq.Handler[T] // Listeners wrapped by the middlewares
 	concurrent  bool
 	mu          sync.RWMutex
 }
@@ -30,17 +35,37 @@ type SyncqComponent[T comparable] struct {
 // Init implements component.Component interface.
 func (c *SyncqComponent[T]) Init(ctx context.Context) error {
 	c.concurrent = c.Options().Concurrent
-	c.eventSystem = event.NewEventSystem[T](!c.Options().Unordered)
+	c.listeners = newListenerSet[T, event.Listener[T]](!c.Options().Unordered)
+	c.handlers = newListenerSet[T, syncq.RequestHandler[T]](true)
+	c.handler = c.invoke
 	return nil
 }
 
+// Use implements syncq.Component interface.
+func (c *SyncqComponent[T]) Use(middlewares ...syncq.Middleware[T]) {
+	if c.concurrent {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	c.middlewares = append(c.middlewares, middlewares...)
+	c.handler = c.chain(c.middlewares, c.invoke)
+}
+
+// chain wraps the handler with the middlewares.
+func (c *SyncqComponent[T]) chain(middlewares []syncq.Middleware[T], handler syncq.Handler[T]) syncq.Handler[T] {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
 // AddListener implements event.ListenerAdder interface.
 func (c *SyncqComponent[T]) AddListener(listener event.Listener[T]) event.ListenerID {
 	if c.concurrent {
 		c.mu.Lock()
 		defer c.mu.Unlock()
 	}
-	return c.eventSystem.AddListener(listener)
+	return c.listeners.add(listener)
 }
 
 // RemoveListener implements event.ListenerRemover interface.
@@ -49,7 +74,7 @@ func (c *SyncqComponent[T]) RemoveListener(id event.ListenerID) bool {
 		c.mu.Lock()
 		defer c.mu.Unlock()
 	}
-	return c.eventSystem.RemoveListener(id)
+	return c.listeners.remove(id)
 }
 
 // HasListener implements event.ListenerChecker interface.
@@ -58,14 +83,147 @@ func (c *SyncqComponent[T]) HasListener(id event.ListenerID) bool {
 		c.mu.RLock()
 		defer c.mu.RUnlock()
 	}
-	return c.eventSystem.HasListener(id)
+	return c.listeners.has(id)
 }
 
-// DispatchEvent implements event.Dispatcher interface.
-func (c *SyncqComponent[T]) DispatchEvent(ctx context.Context, event event.Event[T]) error {
+// AddRequestHandler implements syncq.Component interface.
+func (c *SyncqComponent[T]) AddRequestHandler(handler syncq.RequestHandler[T]) event.ListenerID {
+	if c.concurrent {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	return c.handlers.add(handler)
+}
+
+// RemoveRequestHandler implements syncq.Component interface.
+func (c *SyncqComponent[T]) RemoveRequestHandler(id event.ListenerID) bool {
+	if c.concurrent {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	return c.handlers.remove(id)
+}
+
+// Request implements syncq.Component interface.
+func (c *SyncqComponent[T]) Request(ctx context.Context, req event.Event[T]) (any, error) {
 	if c.concurrent {
 		c.mu.RLock()
-		defer c.mu.RUnlock()
 	}
-	return c.eventSystem.DispatchEvent(ctx, event)
+	handlers := c.handlers.get(req.Typeof())
+	middlewares := c.middlewares
+	if c.concurrent {
+		c.mu.RUnlock()
+	}
+	switch len(handlers) {
+	case 0:
+		return nil, fmt.Errorf("%w %v", syncq.ErrNoHandler, req.Typeof())
+	case 1:
+	default:
+		return nil, fmt.Errorf("%w %v: %d handlers", syncq.ErrMultipleHandlers, req.Typeof(), len(handlers))
+	}
+	var resp any
+	err := c.chain(middlewares, func(ctx context.Context, req event.Event[T]) error {
+		var err error
+		resp, err = handlers[0].listener.HandleRequest(ctx, req)
+		return err
+	})(ctx, req)
+	return resp, err
+}
+
+// DispatchEvent implements event.Dispatcher interface.
+func (c *SyncqComponent[T]) DispatchEvent(ctx context.Context, event event.Event[T]) error {
+	if !c.concurrent {
+		return c.handler(ctx, event)
+	}
+	c.mu.RLock()
+	handler := c.handler
+	c.mu.RUnlock()
+	return handler(ctx, event)
+}
+
+// invoke invokes the listeners of the event.
+func (c *SyncqComponent[T]) invoke(ctx context.Context, event event.Event[T]) error {
+	if !c.concurrent {
+		return c.dispatch(ctx, event, c.listeners.get(event.Typeof()))
+	}
+	c.mu.RLock()
+	listeners := c.listeners.get(event.Typeof())
+	c.mu.RUnlock()
+	return c.fanOut(ctx, event, listeners)
+}
+
+// dispatch invokes the listeners one by one, and returns the joined errors.
+// It stops after a listener returns syncq.ErrStopPropagation.
+func (c *SyncqComponent[T]) dispatch(ctx context.Context, event event.Event[T], listeners []listener[T, event.Listener[T]]) error {
+	var errs []error
+	for i := range listeners {
+		err := listeners[i].listener.HandleEvent(ctx, event)
+		errs = append(errs, err)
+		if errors.Is(err, syncq.ErrStopPropagation) {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fanOut invokes the listeners of each priority concurrently, from the highest
+// priority to the lowest, and returns the joined errors. It stops after the
+// priority in which a listener returns syncq.ErrStopPropagation.
+func (c *SyncqComponent[T]) fanOut(ctx context.Context, event event.Event[T], listeners []listener[T, event.Listener[T]]) error {
+	var errs []error
+	for len(listeners) > 0 {
+		n := 1
+		for n < len(listeners) && listeners[n].priority == listeners[0].priority {
+			n++
+		}
+		err := c.fanOutGroup(ctx, event, listeners[:n])
+		errs = append(errs, err)
+		if errors.Is(err, syncq.ErrStopPropagation) {
+			break
+		}
+		listeners = listeners[n:]
+	}
+	return errors.Join(errs...)
+}
+
+// fanOutGroup invokes the listeners concurrently, at most MaxConcurrency at a
+// time, and returns the joined errors. A panic of a listener is propagated to
+// the caller after all listeners have returned.
+func (c *SyncqComponent[T]) fanOutGroup(ctx context.Context, event event.Event[T], listeners []listener[T, event.Listener[T]]) error {
+	if len(listeners) <= 1 {
+		return c.dispatch(ctx, event, listeners)
+	}
+	var sem chan struct{}
+	if n := c.Options().MaxConcurrency; n > 0 && n < len(listeners) {
+		sem = make(chan struct{}, n)
+	}
+	var (
+		wg        sync.WaitGroup
+		errs      = make([]error, len(listeners))
+		panicked  sync.Once
+		recovered any
+	)
+	for i := range listeners {
+		if sem != nil {
+			sem <- struct{}{}
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					panicked.Do(func() { recovered = r })
+				}
+				if sem != nil {
+					<-sem
+				}
+				wg.Done()
+			}()
+			errs[i] = listeners[i].listener.HandleEvent(ctx, event)
+		}()
+	}
+	wg.Wait()
+	if recovered != nil {
+		panic(recovered)
+	}
+	return errors.Join(errs...)
 }
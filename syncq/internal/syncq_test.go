@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gopherd/core/component"
+	"github.com/gopherd/core/event"
+	"github.com/gopherd/core/op"
+	"github.com/gopherd/core/typing"
+
+	"github.com/gopherd/components/syncq"
+)
+
+type mockEntity struct{}
+
+func (mockEntity) GetComponent(uuid string) component.Component {
+	return nil
+}
+
+func (mockEntity) Logger() *slog.Logger {
+	return slog.Default()
+}
+
+func mustNew(t *testing.T, options syncq.Options) *SyncqComponent[reflect.Type] {
+	t.Helper()
+	comp, err := component.Create(syncq.Name)
+	if err != nil {
+		t.Fatalf("Failed to create component %q: %v", syncq.Name, err)
+	}
+	if err := comp.Setup(mockEntity{}, &component.Config{
+		Name:    syncq.Name,
+		Options: typing.NewRawObject(op.MustResult(json.Marshal(options))),
+	}, false); err != nil {
+		t.Fatalf("Failed to setup component %q: %v", syncq.Name, err)
+	}
+	c := comp.(*SyncqComponent[reflect.Type])
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	return c
+}
+
+type testEvent struct {
+	seq int
+}
+
+func (e *testEvent) Typeof() reflect.Type {
+	return reflect.TypeOf(e)
+}
+
+var testEventType = reflect.TypeOf((*testEvent)(nil))
+
+func TestDispatchEvent(t *testing.T) {
+	c := mustNew(t, syncq.Options{})
+	var got []int
+	errOdd := errors.New("odd")
+	var ids []event.ListenerID
+	for i := 0; i < 4; i++ {
+		ids = append(ids, c.AddListener(event.Listen(testEventType, func(ctx context.Context, e *testEvent) error {
+			got = append(got, i)
+			if i%2 == 1 {
+				return errOdd
+			}
+			return nil
+		})))
+	}
+	if !c.RemoveListener(ids[1]) || c.HasListener(ids[1]) {
+		t.Errorf("Expected listener %d removed", ids[1])
+	}
+	err := c.DispatchEvent(context.Background(), &testEvent{})
+	if want := []int{0, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("Expected listeners %v invoked, but got %v", want, got)
+	}
+	if !errors.Is(err, errOdd) {
+		t.Errorf("Expected %v, but got %v", errOdd, err)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	const n = 4
+	c := mustNew(t, syncq.Options{Concurrent: true})
+	var started sync.WaitGroup
+	started.Add(n)
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = errors.New("listener error")
+		c.AddListener(event.Listen(testEventType, func(ctx context.Context, e *testEvent) error {
+			// Each listener waits for all of them to start.
+			started.Done()
+			started.Wait()
+			return errs[i]
+		}))
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.DispatchEvent(context.Background(), &testEvent{})
+	}()
+	select {
+	case err := <-done:
+		for _, want := range errs {
+			if !errors.Is(err, want) {
+				t.Errorf("Expected %v joined, but got %v", want, err)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected listeners invoked concurrently")
+	}
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	c := mustNew(t, syncq.Options{Concurrent: true, MaxConcurrency: 2})
+	var running, peak atomic.Int32
+	for i := 0; i < 5; i++ {
+		c.AddListener(event.Listen(testEventType, func(ctx context.Context, e *testEvent) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		}))
+	}
+	if err := c.DispatchEvent(context.Background(), &testEvent{}); err != nil {
+		t.Fatalf("Failed to dispatch event: %v", err)
+	}
+	if p := peak.Load(); p != 2 {
+		t.Errorf("Expected at most 2 listeners running, but got %d", p)
+	}
+}
+
+func TestConcurrentPanic(t *testing.T) {
+	c := mustNew(t, syncq.Options{Concurrent: true})
+	var finished atomic.Bool
+	c.AddListener(event.Listen(testEventType, func(ctx context.Context, e *testEvent) error {
+		panic("boom")
+	}))
+	c.AddListener(event.Listen(testEventType, func(ctx context.Context, e *testEvent) error {
+		time.Sleep(10 * time.Millisecond)
+		finished.Store(true)
+		return nil
+	}))
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("Expected panic boom, but got %v", r)
+		}
+		if !finished.Load() {
+			t.Errorf("Expected all listeners finished before the panic is propagated")
+		}
+	}()
+	c.DispatchEvent(context.Background(), &testEvent{})
+}
+
+type ctxKey struct{}
+
+func TestMiddleware(t *testing.T) {
+	c := mustNew(t, syncq.Options{})
+	var trace []string
+	wrap := func(name string) syncq.Middleware[reflect.Type] {
+		return func(next syncq.Handler[reflect.Type]) syncq.Handler[reflect.Type] {
+			return func(ctx context.Context, e event.Event[reflect.Type]) error {
+				trace = append(trace, name+" before")
+				err := next(context.WithValue(ctx, ctxKey{}, name), e)
+				trace = append(trace, name+" after")
+				return err
+			}
+		}
+	}
+	c.Use(wrap("a"), wrap("b"))
+	errListener := errors.New("listener error")
+	c.AddListener(event.Listen(testEventType, func(ctx context.Context, e *testEvent) error {
+		trace = append(trace, "listener "+ctx.Value(ctxKey{}).(string))
+		return errListener
+	}))
+	var observed error
+	c.Use(syncq.Observe(func(ctx context.Context, e event.Event[reflect.Type], elapsed time.Duration, err error) {
+		observed = err
+	}))
+
+	if err := c.DispatchEvent(context.Background(), &testEvent{}); !errors.Is(err, errListener) {
+		t.Errorf("Expected %v, but got %v", errListener, err)
+	}
+	if want := []string{"a before", "b before", "listener b", "b after", "a after"}; !slices.Equal(trace, want) {
+		t.Errorf("Expected trace %v, but got %v", want, trace)
+	}
+	if !errors.Is(observed, errListener) {
+		t.Errorf("Expected %v observed, but got %v", errListener, observed)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	for _, concurrent := range []bool{false, true} {
+		c := mustNew(t, syncq.Options{Concurrent: concurrent})
+		c.Use(syncq.Recover[reflect.Type]())
+		for i := 0; i < 2; i++ {
+			c.AddListener(event.Listen(testEventType, func(ctx context.Context, e *testEvent) error {
+				panic("boom")
+			}))
+		}
+		var pe *syncq.PanicError
+		if err := c.DispatchEvent(context.Background(), &testEvent{}); !errors.As(err, &pe) || pe.Value != "boom" {
+			t.Errorf("Expected panic error with value boom in concurrent mode %v, but got %v", concurrent, err)
+		}
+	}
+}
+
+type testRequest struct {
+	name string
+}
+
+func (r *testRequest) Typeof() reflect.Type {
+	return reflect.TypeOf(r)
+}
+
+type testResponse struct {
+	greeting string
+}
+
+func TestCall(t *testing.T) {
+	c := mustNew(t, syncq.Options{})
+	requestType := reflect.TypeOf((*testRequest)(nil))
+	if _, err := syncq.Call[*testRequest, *testResponse](context.Background(), c, &testRequest{}); !errors.Is(err, syncq.ErrNoHandler) {
+		t.Errorf("Expected %v, but got %v", syncq.ErrNoHandler, err)
+	}
+
+	var dispatched []event.Event[reflect.Type]
+	c.Use(func(next syncq.Handler[reflect.Type]) syncq.Handler[reflect.Type] {
+		return func(ctx context.Context, e event.Event[reflect.Type]) error {
+			dispatched = append(dispatched, e)
+			return next(ctx, e)
+		}
+	})
+	calls := 0
+	id := c.AddRequestHandler(syncq.Handle(requestType, func(ctx context.Context, req *testRequest) (*testResponse, error) {
+		calls++
+		return &testResponse{greeting: "hello " + req.name}, nil
+	}))
+	req := &testRequest{name: "gopher"}
+	resp, err := syncq.Call[*testRequest, *testResponse](context.Background(), c, req)
+	if err != nil {
+		t.Fatalf("Failed to call: %v", err)
+	}
+	if resp.greeting != "hello gopher" {
+		t.Errorf("Expected response %q, but got %q", "hello gopher", resp.greeting)
+	}
+	if len(dispatched) != 1 || dispatched[0] != req {
+		t.Errorf("Expected the request passed to the middleware, but got %v", dispatched)
+	}
+	if _, err := syncq.Call[*testRequest, string](context.Background(), c, req); err == nil {
+		t.Errorf("Expected error for unexpected response type, but got nil")
+	}
+
+	c.AddRequestHandler(syncq.Handle(requestType, func(ctx context.Context, req *testRequest) (*testResponse, error) {
+		calls++
+		return nil, nil
+	}))
+	calls = 0
+	if _, err := syncq.Call[*testRequest, *testResponse](context.Background(), c, req); !errors.Is(err, syncq.ErrMultipleHandlers) {
+		t.Errorf("Expected %v, but got %v", syncq.ErrMultipleHandlers, err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no handler called, but got %d calls", calls)
+	}
+	if !c.RemoveRequestHandler(id) {
+		t.Errorf("Expected request handler %d removed", id)
+	}
+	if resp, err := syncq.Call[*testRequest, *testResponse](context.Background(), c, req); err != nil || resp != nil {
+		t.Errorf("Expected nil response and error, but got %v and %v", resp, err)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	for _, unordered := range []bool{false, true} {
+		c := mustNew(t, syncq.Options{Unordered: unordered})
+		var got []int
+		add := func(p int) event.ListenerID {
+			return c.AddListener(syncq.WithPriority(event.Listen(testEventType, func(ctx context.Context, e *testEvent) error {
+				got = append(got, p)
+				return nil
+			}), p))
+		}
+		for _, p := range []int{0, 10, 1, 5, 1, 10} {
+			add(p)
+		}
+		c.RemoveListener(add(5))
+		c.DispatchEvent(context.Background(), &testEvent{})
+		if want := []int{10, 10, 5, 1, 1, 0}; !slices.Equal(got, want) {
+			t.Errorf("Expected priorities %v invoked in unordered mode %v, but got %v", want, unordered, got)
+		}
+	}
+}
+
+func TestStopPropagation(t *testing.T) {
+	for _, concurrent := range []bool{false, true} {
+		c := mustNew(t, syncq.Options{Concurrent: concurrent})
+		var invoked atomic.Int32
+		errVeto := fmt.Errorf("%w: banned", syncq.ErrStopPropagation)
+		for _, p := range []int{2, 1, 1, 0} {
+			c.AddListener(syncq.WithPriority(event.Listen(testEventType, func(ctx context.Context, e *testEvent) error {
+				invoked.Add(1)
+				if p == 1 {
+					return errVeto
+				}
+				return nil
+			}), p))
+		}
+		err := c.DispatchEvent(context.Background(), &testEvent{})
+		if !errors.Is(err, syncq.ErrStopPropagation) {
+			t.Errorf("Expected %v in concurrent mode %v, but got %v", syncq.ErrStopPropagation, concurrent, err)
+		}
+		// The listeners of priority 0 are not invoked, and in sequential mode
+		// neither is the second listener of priority 1.
+		want := int32(2)
+		if concurrent {
+			want = 3
+		}
+		if n := invoked.Load(); n != want {
+			t.Errorf("Expected %d listeners invoked in concurrent mode %v, but got %d", want, concurrent, n)
+		}
+	}
+}
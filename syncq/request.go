@@ -0,0 +1,65 @@
+package syncq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gopherd/core/event"
+)
+
+var (
+	// ErrNoHandler is returned when no handler is registered for the request type.
+	ErrNoHandler = errors.New("syncq: no handler for request")
+
+	// ErrMultipleHandlers is returned when more than one handler is registered
+	// for the request type.
+	ErrMultipleHandlers = errors.New("syncq: multiple handlers for request")
+)
+
+// RequestHandler handles requests of a type and returns the responses.
+type RequestHandler[T comparable] interface {
+	// EventType returns the type of requests this handler handles.
+	EventType() T
+	// HandleRequest processes the request and returns the response.
+	HandleRequest(ctx context.Context, req event.Event[T]) (any, error)
+}
+
+// Handle creates a RequestHandler for the given request type and handler function.
+func Handle[H ~func(context.Context, Req) (Resp, error), Req event.Event[T], Resp any, T comparable](eventType T, handler H) RequestHandler[T] {
+	return requestHandlerFunc[H, Req, Resp, T]{eventType, handler}
+}
+
+type requestHandlerFunc[H ~func(context.Context, Req) (Resp, error), Req event.Event[T], Resp any, T comparable] struct {
+	eventType T
+	handler   H
+}
+
+// EventType implements the RequestHandler interface.
+func (h requestHandlerFunc[H, Req, Resp, T]) EventType() T {
+	return h.eventType
+}
+
+// HandleRequest implements the RequestHandler interface.
+func (h requestHandlerFunc[H, Req, Resp, T]) HandleRequest(ctx context.Context, req event.Event[T]) (any, error) {
+	if r, ok := req.(Req); ok {
+		return h.handler(ctx, r)
+	}
+	return nil, fmt.Errorf("%w: got %T for type %v", event.ErrUnexpectedEventType, req, req.Typeof())
+}
+
+// Call sends the request to the only handler registered for its type and
+// returns the typed response. It returns ErrNoHandler or ErrMultipleHandlers
+// without calling any handler if there is not exactly one.
+func Call[Req event.Event[T], Resp any, T comparable](ctx context.Context, q Component[T], req Req) (Resp, error) {
+	var zero Resp
+	resp, err := q.Request(ctx, req)
+	if resp == nil {
+		return zero, err
+	}
+	r, ok := resp.(Resp)
+	if !ok {
+		return zero, errors.Join(err, fmt.Errorf("syncq: unexpected response type %T for request %T", resp, req))
+	}
+	return r, err
+}
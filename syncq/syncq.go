@@ -0,0 +1,119 @@
+package syncq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/gopherd/core/event"
+)
+
+// Component represents the syncq component API.
+type Component[T comparable] interface {
+	event.EventSystem[T]
+
+	// Use appends middlewares wrapping every DispatchEvent. The middlewares
+	// are applied in order, so the first one is the outermost.
+	Use(middlewares ...Middleware[T])
+
+	// AddRequestHandler adds a handler for requests of its type, and returns
+	// its ID for RemoveRequestHandler.
+	AddRequestHandler(handler RequestHandler[T]) event.ListenerID
+
+	// RemoveRequestHandler removes the request handler by ID. It returns false
+	// if the handler is not found.
+	RemoveRequestHandler(id event.ListenerID) bool
+
+	// Request sends the request to the only handler registered for its type
+	// through the middlewares, and returns the response. See Call for the
+	// typed variant.
+	Request(ctx context.Context, req event.Event[T]) (any, error)
+}
+
+// ErrStopPropagation is returned, or wrapped, by a listener to stop invoking
+// the listeners of lower priorities, e.g. to veto an event. DispatchEvent
+// returns the error of the listener, so the dispatcher can check whether the
+// event was stopped by errors.Is(err, ErrStopPropagation).
+var ErrStopPropagation = errors.New("syncq: stop propagation")
+
+// PriorityListener is an optional interface implemented by listeners to be
+// invoked ahead of the listeners with lower priorities. Listeners that do not
+// implement PriorityListener have priority 0. In concurrent mode, listeners
+// with the same priority run concurrently, and priorities run in turn.
+type PriorityListener interface {
+	// ListenerPriority returns the priority of the listener.
+	ListenerPriority() int
+}
+
+// WithPriority returns a listener with the given priority, see PriorityListener.
+func WithPriority[T comparable](listener event.Listener[T], priority int) event.Listener[T] {
+	return priorityListener[T]{listener, priority}
+}
+
+type priorityListener[T comparable] struct {
+	event.Listener[T]
+	priority int
+}
+
+// ListenerPriority implements the PriorityListener interface.
+func (l priorityListener[T]) ListenerPriority() int {
+	return l.priority
+}
+
+// Handler dispatches an event to the listeners.
+type Handler[T comparable] func(ctx context.Context, e event.Event[T]) error
+
+// Middleware wraps a Handler with additional behavior, e.g. tracing, timing
+// or recovery. It may modify the context, the event or the error, or return
+// without calling next to skip the listeners.
+type Middleware[T comparable] func(next Handler[T]) Handler[T]
+
+// PanicError represents a panic recovered from a listener by Recover.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("syncq: listener panicked: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recover returns a middleware that recovers the panic of a listener and
+// returns it as a *PanicError.
+func Recover[T comparable]() Middleware[T] {
+	return func(next Handler[T]) Handler[T] {
+		return func(ctx context.Context, e event.Event[T]) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}()
+			return next(ctx, e)
+		}
+	}
+}
+
+// Observe returns a middleware that calls fn after each dispatch with the
+// event, the elapsed time and the error returned from the listeners, e.g. for
+// logging slow events or collecting latency statistics.
+func Observe[T comparable](fn func(ctx context.Context, e event.Event[T], elapsed time.Duration, err error)) Middleware[T] {
+	return func(next Handler[T]) Handler[T] {
+		return func(ctx context.Context, e event.Event[T]) error {
+			start := time.Now()
+			err := next(ctx, e)
+			fn(ctx, e, time.Since(start), err)
+			return err
+		}
+	}
+}
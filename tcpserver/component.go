@@ -11,16 +11,69 @@ const Name = "github.com/gopherd/components/tcpserver";
 
 // Options represents the configuration options for the TCP server.
 type Options struct {
-	// Network represents the network type, either tcp or unix.
+	// Network represents the network type: tcp, tcp4, tcp6, unix or unixpacket.
 	Network string
-	// Addr represents the host:port address.
+	// Addr represents the host:port address, or the socket file path for unix networks.
 	Addr string
+	// UnixSocketPerm specifies the file permissions of the unix socket file in octal, e.g. "0660".
+	// If empty, the permissions are determined by the process umask.
+	UnixSocketPerm string
 	// KeepAlive specifies the keep-alive period for an active network connection.
 	KeepAlive int
 	// ReadTimeout specifies the maximum duration for reading the entire request.
 	ReadTimeout int
 	// WriteTimeout specifies the maximum duration before timing out writes of the response.
 	WriteTimeout int
+	// Framing specifies the framing codec used by sessions.
+	// Supported values:
+	//   - "uint16be": 2-byte big-endian length prefix
+	//   - "uint16le": 2-byte little-endian length prefix
+	//   - "uint32be": 4-byte big-endian length prefix
+	//   - "uint32le": 4-byte little-endian length prefix
+	//   - "varint": unsigned varint length prefix
+	//   - "line": newline-delimited frames
+	Framing string
+	// MaxFrameSize is the maximum size in bytes of a frame, excluding the framing overhead.
+	MaxFrameSize int
+	// SendQueueSize is the maximum number of frames pending to be written per session.
+	SendQueueSize int
+	// TLS specifies the TLS configuration. TLS is enabled if the certificate file is set.
+	TLS TLSOptions
+	// MaxConns is the maximum number of concurrent connections. 0 means no limit.
+	MaxConns int
+	// MaxConnsPerIP is the maximum number of concurrent connections per peer IP. 0 means no limit.
+	MaxConnsPerIP int
+	// AcceptRate is the maximum number of connections accepted per second. 0 means no limit.
+	AcceptRate int
+	// AcceptBurst is the maximum number of connections accepted at once.
+	// Defaults to AcceptRate.
+	AcceptBurst int
+	// AllowCIDRs specifies the CIDR blocks allowed to connect, e.g. "10.0.0.0/8".
+	// If empty, all peers not denied are allowed.
+	AllowCIDRs []string
+	// DenyCIDRs specifies the CIDR blocks denied to connect. It takes precedence over AllowCIDRs.
+	DenyCIDRs []string
+	// GracefulShutdown determines whether Shutdown waits for active connections to finish.
+	// If true, Shutdown stops accepting, cancels the handlers' context (or calls OnShutdown
+	// of session handlers implementing ShutdownHandler) and waits for the connections to
+	// finish until the shutdown context is done or ShutdownTimeout elapses, then
+	// force-closes the rest. Otherwise, active connections are closed immediately.
+	GracefulShutdown bool
+	// ShutdownTimeout specifies the maximum duration in seconds for graceful shutdown
+	// if the shutdown context has no deadline.
+	ShutdownTimeout int
+	// ProxyProtocol determines whether accepted connections start with a PROXY protocol
+	// v1 or v2 header. If true, the client address from the header is exposed as the
+	// connection's remote address and used by per-IP limits and filters.
+	ProxyProtocol bool
+	// ProxyTrustedCIDRs specifies the CIDR blocks of upstream proxies allowed to send
+	// PROXY protocol headers. Connections from other peers are served as direct connections.
+	// It is required if ProxyProtocol is enabled on any listener.
+	ProxyTrustedCIDRs []string
+	// Listeners specifies multiple listeners whose connections are served by the same handler.
+	// If empty, a single listener is created from Network, Addr, UnixSocketPerm, KeepAlive,
+	// TLS and ProxyProtocol.
+	Listeners []ListenerOptions
 }
 
 func (x *Options) OnLoaded() {
@@ -28,4 +81,63 @@ func (x *Options) OnLoaded() {
 	op.SetDefault(&x.KeepAlive, 300)
 	op.SetDefault(&x.ReadTimeout, 10)
 	op.SetDefault(&x.WriteTimeout, 10)
+	op.SetDefault(&x.Framing, "uint32be")
+	op.SetDefault(&x.MaxFrameSize, 1048576)
+	op.SetDefault(&x.SendQueueSize, 256)
+	op.SetDefault(&x.ShutdownTimeout, 30)
+}
+
+// ListenerOptions represents the configuration of a listener.
+type ListenerOptions struct {
+	// Network represents the network type: tcp, tcp4, tcp6, unix or unixpacket.
+	Network string
+	// Addr represents the host:port address, or the socket file path for unix networks.
+	Addr string
+	// UnixSocketPerm specifies the file permissions of the unix socket file in octal, e.g. "0660".
+	// If empty, the permissions are determined by the process umask.
+	UnixSocketPerm string
+	// KeepAlive specifies the keep-alive period in seconds for an active network connection.
+	KeepAlive int
+	// TLS specifies the TLS configuration. TLS is enabled if the certificate file is set.
+	TLS TLSOptions
+	// ProxyProtocol determines whether accepted connections start with a PROXY protocol header.
+	ProxyProtocol bool
+}
+
+func (x *ListenerOptions) OnLoaded() {
+	op.SetDefault(&x.Network, "tcp")
+	op.SetDefault(&x.KeepAlive, 300)
+}
+
+// TLSOptions represents the TLS configuration for the TCP server.
+// The certificate files are reloaded when they change on disk.
+type TLSOptions struct {
+	// CertFile is the path to the PEM encoded certificate file.
+	CertFile string
+	// KeyFile is the path to the PEM encoded private key file.
+	KeyFile string
+	// ClientCAFile is the path to the PEM encoded CA bundle used to verify client certificates.
+	ClientCAFile string
+	// MinVersion specifies the minimum TLS version: 1.0, 1.1, 1.2 or 1.3.
+	MinVersion string
+	// RequireClientCert determines whether clients must present a certificate
+	// signed by the client CA bundle (mutual TLS).
+	RequireClientCert bool
+}
+
+func (x *TLSOptions) OnLoaded() {
+	op.SetDefault(&x.MinVersion, "1.2")
+}
+
+// Component represents the TCP server component API.
+type Component interface {
+	// SetConnHandler sets the handler for accepted connections.
+	// Each accepted connection is served by the handler on its own goroutine.
+	SetConnHandler(handler ConnHandler)
+	// SetSessionHandler sets the handler for framed sessions.
+	// It replaces the connection handler: each accepted connection is wrapped
+	// into a session using the configured framing codec.
+	SetSessionHandler(handler SessionHandler)
+	// Stats returns the connection statistics of the server.
+	Stats() Stats
 }
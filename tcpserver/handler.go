@@ -0,0 +1,22 @@
+package tcpserver
+
+import (
+	"context"
+	"net"
+)
+
+// ConnHandler handles connections accepted by the TCP server.
+type ConnHandler interface {
+	// ServeConn serves an accepted connection. It is called on a dedicated goroutine
+	// and the connection is closed by the server after ServeConn returns.
+	// The context is cancelled when the server is shutting down.
+	ServeConn(ctx context.Context, conn net.Conn)
+}
+
+// ConnHandlerFunc is an adapter to allow the use of ordinary functions as ConnHandler.
+type ConnHandlerFunc func(ctx context.Context, conn net.Conn)
+
+// ServeConn implements ConnHandler.ServeConn.
+func (f ConnHandlerFunc) ServeConn(ctx context.Context, conn net.Conn) {
+	f(ctx, conn)
+}
@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/gopherd/components/tcpserver"
+)
+
+// codec reads and writes frames from and to a buffered stream.
+type codec interface {
+	// readFrame reads the next frame.
+	readFrame(r *bufio.Reader) ([]byte, error)
+	// writeFrame writes the frame without flushing.
+	writeFrame(w *bufio.Writer, frame []byte) error
+	// checkFrame returns an error if the frame cannot be written.
+	checkFrame(frame []byte) error
+}
+
+// defaultMaxFrameSize is used when no positive maximum frame size is configured.
+const defaultMaxFrameSize = 1 << 20
+
+// maxFrameOverhead is the maximum size in bytes of the framing around a frame.
+const maxFrameOverhead = binary.MaxVarintLen64
+
+// newCodec creates a codec by the framing name.
+func newCodec(framing string, maxFrameSize int) (codec, error) {
+	if maxFrameSize <= 0 {
+		maxFrameSize = defaultMaxFrameSize
+	}
+	switch framing {
+	case "uint16be":
+		return &lengthCodec{size: 2, order: binary.BigEndian, max: min(maxFrameSize, math.MaxUint16)}, nil
+	case "uint16le":
+		return &lengthCodec{size: 2, order: binary.LittleEndian, max: min(maxFrameSize, math.MaxUint16)}, nil
+	case "", "uint32be":
+		return &lengthCodec{size: 4, order: binary.BigEndian, max: maxFrameSize}, nil
+	case "uint32le":
+		return &lengthCodec{size: 4, order: binary.LittleEndian, max: maxFrameSize}, nil
+	case "varint":
+		return &varintCodec{max: maxFrameSize}, nil
+	case "line":
+		return &lineCodec{max: maxFrameSize}, nil
+	default:
+		return nil, fmt.Errorf("tcpserver: unsupported framing %q", framing)
+	}
+}
+
+// lengthCodec frames data with a fixed-size length prefix.
+type lengthCodec struct {
+	size  int // 2 or 4
+	order binary.ByteOrder
+	max   int
+}
+
+func (c *lengthCodec) readFrame(r *bufio.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:c.size]); err != nil {
+		return nil, err
+	}
+	var n int
+	if c.size == 2 {
+		n = int(c.order.Uint16(header[:]))
+	} else {
+		n = int(c.order.Uint32(header[:]))
+	}
+	if n > c.max {
+		return nil, tcpserver.ErrFrameTooLarge
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (c *lengthCodec) checkFrame(frame []byte) error {
+	if len(frame) > c.max {
+		return tcpserver.ErrFrameTooLarge
+	}
+	return nil
+}
+
+func (c *lengthCodec) writeFrame(w *bufio.Writer, frame []byte) error {
+	if err := c.checkFrame(frame); err != nil {
+		return err
+	}
+	var header [4]byte
+	if c.size == 2 {
+		c.order.PutUint16(header[:], uint16(len(frame)))
+	} else {
+		c.order.PutUint32(header[:], uint32(len(frame)))
+	}
+	if _, err := w.Write(header[:c.size]); err != nil {
+		return err
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// varintCodec frames data with an unsigned varint length prefix.
+type varintCodec struct {
+	max int
+}
+
+func (c *varintCodec) readFrame(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(c.max) {
+		return nil, tcpserver.ErrFrameTooLarge
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (c *varintCodec) checkFrame(frame []byte) error {
+	if len(frame) > c.max {
+		return tcpserver.ErrFrameTooLarge
+	}
+	return nil
+}
+
+func (c *varintCodec) writeFrame(w *bufio.Writer, frame []byte) error {
+	if err := c.checkFrame(frame); err != nil {
+		return err
+	}
+	var header [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(header[:], uint64(len(frame)))
+	if _, err := w.Write(header[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// lineCodec frames data delimited by newlines. A trailing "\r" is trimmed
+// from received frames.
+type lineCodec struct {
+	max int
+}
+
+func (c *lineCodec) readFrame(r *bufio.Reader) ([]byte, error) {
+	var frame []byte
+	for {
+		line, err := r.ReadSlice('\n')
+		if len(frame)+len(line) > c.max+2 {
+			return nil, tcpserver.ErrFrameTooLarge
+		}
+		frame = append(frame, line...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	frame = frame[:len(frame)-1]
+	if n := len(frame); n > 0 && frame[n-1] == '\r' {
+		frame = frame[:n-1]
+	}
+	if len(frame) > c.max {
+		return nil, tcpserver.ErrFrameTooLarge
+	}
+	return frame, nil
+}
+
+// checkFrame rejects frames containing the delimiter, which would be
+// received as multiple frames by the peer.
+func (c *lineCodec) checkFrame(frame []byte) error {
+	if len(frame) > c.max {
+		return tcpserver.ErrFrameTooLarge
+	}
+	if bytes.IndexByte(frame, '\n') >= 0 {
+		return tcpserver.ErrInvalidFrame
+	}
+	return nil
+}
+
+func (c *lineCodec) writeFrame(w *bufio.Writer, frame []byte) error {
+	if err := c.checkFrame(frame); err != nil {
+		return err
+	}
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
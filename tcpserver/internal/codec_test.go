@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gopherd/components/tcpserver"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	frames := [][]byte{
+		[]byte("hello"),
+		{},
+		[]byte(strings.Repeat("x", 300)),
+		[]byte("world"),
+	}
+	tests := []string{"uint16be", "uint16le", "uint32be", "uint32le", "varint", "line"}
+
+	for _, framing := range tests {
+		t.Run(framing, func(t *testing.T) {
+			c, err := newCodec(framing, 1024)
+			if err != nil {
+				t.Fatalf("Failed to create codec: %v", err)
+			}
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+			for _, frame := range frames {
+				if err := c.writeFrame(w, frame); err != nil {
+					t.Fatalf("Failed to write frame: %v", err)
+				}
+			}
+			w.Flush()
+
+			r := bufio.NewReader(&buf)
+			for i, want := range frames {
+				got, err := c.readFrame(r)
+				if err != nil {
+					t.Fatalf("Failed to read frame %d: %v", i, err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("Frame %d: expected %q, but got %q", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCodecFrameTooLarge(t *testing.T) {
+	tests := []string{"uint16be", "uint32le", "varint", "line"}
+
+	for _, framing := range tests {
+		t.Run(framing, func(t *testing.T) {
+			large, err := newCodec(framing, 1024)
+			if err != nil {
+				t.Fatalf("Failed to create codec: %v", err)
+			}
+			small, err := newCodec(framing, 16)
+			if err != nil {
+				t.Fatalf("Failed to create codec: %v", err)
+			}
+			frame := []byte(strings.Repeat("x", 64))
+
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+			if err := small.writeFrame(w, frame); !errors.Is(err, tcpserver.ErrFrameTooLarge) {
+				t.Errorf("Expected ErrFrameTooLarge on write, but got %v", err)
+			}
+			if err := large.writeFrame(w, frame); err != nil {
+				t.Fatalf("Failed to write frame: %v", err)
+			}
+			w.Flush()
+			if _, err := small.readFrame(bufio.NewReader(&buf)); !errors.Is(err, tcpserver.ErrFrameTooLarge) {
+				t.Errorf("Expected ErrFrameTooLarge on read, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewCodecUnsupported(t *testing.T) {
+	if _, err := newCodec("unknown", 0); err == nil {
+		t.Error("Expected an error, but got nil")
+	}
+}
+
+func TestNewCodecDefaultMaxFrameSize(t *testing.T) {
+	c, err := newCodec("uint32be", 0)
+	if err != nil {
+		t.Fatalf("Failed to create codec: %v", err)
+	}
+	if err := c.checkFrame(make([]byte, defaultMaxFrameSize)); err != nil {
+		t.Errorf("Unexpected error for a frame of the max size: %v", err)
+	}
+	if err := c.checkFrame(make([]byte, defaultMaxFrameSize+1)); !errors.Is(err, tcpserver.ErrFrameTooLarge) {
+		t.Errorf("Expected ErrFrameTooLarge, but got %v", err)
+	}
+	// A huge length prefix must be rejected before allocating the frame.
+	r := bufio.NewReader(bytes.NewReader([]byte{0x7f, 0xff, 0xff, 0xff}))
+	if _, err := c.readFrame(r); !errors.Is(err, tcpserver.ErrFrameTooLarge) {
+		t.Errorf("Expected ErrFrameTooLarge, but got %v", err)
+	}
+}
+
+func TestLineCodecDelimiter(t *testing.T) {
+	c, err := newCodec("line", 1024)
+	if err != nil {
+		t.Fatalf("Failed to create codec: %v", err)
+	}
+	w := bufio.NewWriter(&bytes.Buffer{})
+	if err := c.writeFrame(w, []byte("a\nb")); !errors.Is(err, tcpserver.ErrInvalidFrame) {
+		t.Errorf("Expected ErrInvalidFrame, but got %v", err)
+	}
+}
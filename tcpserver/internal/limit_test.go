@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIPFilter(t *testing.T) {
+	f, err := newIPFilter(
+		[]string{"10.0.0.0/8", "2001:db8::/32"},
+		[]string{"10.1.0.0/16"},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create filter: %v", err)
+	}
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"10.0.0.1", true},
+		{"10.1.2.3", false},
+		{"192.168.1.1", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+	}
+	for _, tt := range tests {
+		if got := f.allowed(netip.MustParseAddr(tt.ip)); got != tt.expected {
+			t.Errorf("allowed(%s) = %v, want %v", tt.ip, got, tt.expected)
+		}
+	}
+
+	if _, err := newIPFilter([]string{"invalid"}, nil); err == nil {
+		t.Error("Expected an error for invalid CIDR, but got nil")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(10, 2)
+	now := b.last
+	for i := 0; i < 2; i++ {
+		if !b.allow(now) {
+			t.Fatalf("Expected token %d to be allowed", i)
+		}
+	}
+	if b.allow(now) {
+		t.Error("Expected burst to be exhausted")
+	}
+	if !b.allow(now.Add(100 * time.Millisecond)) {
+		t.Error("Expected a token to be refilled after 100ms")
+	}
+	if b.allow(now.Add(100 * time.Millisecond)) {
+		t.Error("Expected only one token to be refilled")
+	}
+}
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"cmp"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gopherd/components/tcpserver"
+)
+
+// listenerOptions returns the listener specs. If no listener is configured,
+// a single listener is built from the top-level options.
+func (c *TCPServerComponent) listenerOptions() []tcpserver.ListenerOptions {
+	options := c.Options()
+	if len(options.Listeners) > 0 {
+		return options.Listeners
+	}
+	return []tcpserver.ListenerOptions{{
+		Network:        options.Network,
+		Addr:           options.Addr,
+		UnixSocketPerm: options.UnixSocketPerm,
+		KeepAlive:      options.KeepAlive,
+		TLS:            options.TLS,
+		ProxyProtocol:  options.ProxyProtocol,
+	}}
+}
+
+// listen creates all configured listeners. Listeners already created are
+// closed if any of them fails.
+func (c *TCPServerComponent) listen() error {
+	specs := c.listenerOptions()
+	c.listeners = make([]net.Listener, 0, len(specs))
+	for _, spec := range specs {
+		l, err := c.listenOne(spec)
+		if err != nil {
+			for _, l := range c.listeners {
+				l.Close()
+			}
+			c.listeners = nil
+			return fmt.Errorf("tcpserver: listen %s %s: %w", spec.Network, spec.Addr, err)
+		}
+		c.listeners = append(c.listeners, l)
+	}
+	return nil
+}
+
+// listenOne creates the listener according to the network option,
+// and wraps it with PROXY protocol and TLS if configured.
+func (c *TCPServerComponent) listenOne(spec tcpserver.ListenerOptions) (net.Listener, error) {
+	network := cmp.Or(spec.Network, "tcp")
+	var l net.Listener
+	var err error
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		l, err = listenTCP(network, spec.Addr, time.Duration(spec.KeepAlive)*time.Second)
+	case "unix", "unixpacket":
+		l, err = listenUnix(network, spec.Addr, spec.UnixSocketPerm)
+	default:
+		err = fmt.Errorf("unsupported network %q", network)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The PROXY protocol header precedes the TLS handshake.
+	if spec.ProxyProtocol {
+		l = &proxyListener{Listener: l, trusted: c.proxyTrusted}
+	}
+	if spec.TLS.CertFile != "" {
+		config, err := newTLSConfig(spec.TLS, c.Logger())
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		l = tls.NewListener(l, config)
+	}
+	return l, nil
+}
+
+// listenTCP creates a tcp listener with keepalive enabled if keepAlive is positive.
+func listenTCP(network, addr string, keepAlive time.Duration) (net.Listener, error) {
+	a, err := net.ResolveTCPAddr(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.ListenTCP(network, a)
+	if err != nil {
+		return nil, err
+	}
+	if keepAlive > 0 {
+		return newTCPKeepAliveListener(l, keepAlive), nil
+	}
+	return l, nil
+}
+
+// listenUnix creates a unix listener. A stale socket file left behind by a
+// previous process is removed, and the file permissions are applied if perm is not empty.
+func listenUnix(network, path, perm string) (net.Listener, error) {
+	var mode fs.FileMode
+	if perm != "" {
+		m, err := strconv.ParseUint(perm, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("tcpserver: invalid unix socket perm %q: %w", perm, err)
+		}
+		mode = fs.FileMode(m).Perm()
+	}
+	abstract := len(path) > 0 && path[0] == '@'
+	if !abstract {
+		if err := removeStaleSocket(network, path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.ListenUnix(network, &net.UnixAddr{Name: path, Net: network})
+	if err != nil {
+		return nil, err
+	}
+	if perm != "" && !abstract {
+		if err := os.Chmod(path, mode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// removeStaleSocket removes the socket file if no process is listening on it.
+func removeStaleSocket(network, path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("tcpserver: %s already exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout(network, path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("tcpserver: socket %s is in use", path)
+	}
+	return os.Remove(path)
+}
+
+// tcpKeepAliveListener wraps TCPListener with a keepalive duration
+type tcpKeepAliveListener struct {
+	*net.TCPListener
+	duration time.Duration
+}
+
+// newTCPKeepAliveListener creates a TCPKeepAliveListener
+func newTCPKeepAliveListener(ln *net.TCPListener, d time.Duration) *tcpKeepAliveListener {
+	return &tcpKeepAliveListener{
+		TCPListener: ln,
+		duration:    d,
+	}
+}
+
+// Accept implements net.Listener Accept method
+func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
+	tc, err := ln.AcceptTCP()
+	if err != nil {
+		return
+	}
+	tc.SetKeepAlive(true)
+	if ln.duration == 0 {
+		ln.duration = 3 * time.Minute
+	}
+	tc.SetKeepAlivePeriod(ln.duration)
+	return tc, nil
+}
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyHeaderTimeout is the maximum duration for reading the PROXY protocol header.
+var proxyHeaderTimeout = 5 * time.Second
+
+// errInvalidProxyHeader is returned when the PROXY protocol header is malformed.
+var errInvalidProxyHeader = errors.New("tcpserver: invalid PROXY protocol header")
+
+// proxyV2Signature is the signature of a PROXY protocol v2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyV1MaxLength is the maximum length of a PROXY protocol v1 header, including CRLF.
+const proxyV1MaxLength = 107
+
+// proxyListener wraps connections accepted from trusted peers with proxyConn.
+type proxyListener struct {
+	net.Listener
+	trusted []netip.Prefix
+}
+
+// Accept implements net.Listener Accept method
+func (ln *proxyListener) Accept() (net.Conn, error) {
+	conn, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !containsAddr(ln.trusted, remoteIP(conn.RemoteAddr())) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn}, nil
+}
+
+// proxyConn reads the PROXY protocol header before any data and reports the
+// client address from the header as the remote address.
+type proxyConn struct {
+	net.Conn
+	once   sync.Once
+	err    error
+	remote net.Addr // Client address, nil if not provided by the header
+}
+
+// readHeader reads the PROXY protocol header once.
+func (c *proxyConn) readHeader() error {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.remote, c.err = readProxyHeader(c.Conn)
+		c.Conn.SetReadDeadline(time.Time{})
+	})
+	return c.err
+}
+
+// Read implements net.Conn Read method
+func (c *proxyConn) Read(b []byte) (int, error) {
+	if err := c.readHeader(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+// RemoteAddr implements net.Conn RemoteAddr method
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if c.readHeader() == nil && c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// NetConn returns the underlying connection.
+func (c *proxyConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// readProxyConnHeader reads the PROXY protocol header if conn or the
+// connection it wraps is a proxyConn.
+func readProxyConnHeader(conn net.Conn) error {
+	for {
+		if pc, ok := conn.(*proxyConn); ok {
+			return pc.readHeader()
+		}
+		nc, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		conn = nc.NetConn()
+	}
+}
+
+// readProxyHeader reads a PROXY protocol v1 or v2 header and returns the
+// client address, or nil if the header carries no address.
+func readProxyHeader(r io.Reader) (net.Addr, error) {
+	// The shortest v1 header "PROXY UNKNOWN\r\n" is longer than the v2 signature.
+	var buf [proxyV1MaxLength]byte
+	if _, err := io.ReadFull(r, buf[:len(proxyV2Signature)]); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(buf[:len(proxyV2Signature)], proxyV2Signature) {
+		return readProxyV2Header(r)
+	}
+	if !bytes.HasPrefix(buf[:], []byte("PROXY ")) {
+		return nil, errInvalidProxyHeader
+	}
+	n := len(proxyV2Signature)
+	for n < 2 || buf[n-2] != '\r' || buf[n-1] != '\n' {
+		if n == len(buf) {
+			return nil, errInvalidProxyHeader
+		}
+		if _, err := io.ReadFull(r, buf[n:n+1]); err != nil {
+			return nil, err
+		}
+		n++
+	}
+	return parseProxyV1Header(string(buf[:n-2]))
+}
+
+// parseProxyV1Header parses a PROXY protocol v1 header line without CRLF.
+func parseProxyV1Header(line string) (net.Addr, error) {
+	fields := strings.Split(line, " ")
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, errInvalidProxyHeader
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, errInvalidProxyHeader
+	}
+	if len(fields) != 6 {
+		return nil, errInvalidProxyHeader
+	}
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil || ip.Is4() != (fields[1] == "TCP4") {
+		return nil, errInvalidProxyHeader
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, errInvalidProxyHeader
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+// readProxyV2Header reads the rest of a PROXY protocol v2 header after the signature.
+func readProxyV2Header(r io.Reader) (net.Addr, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	version, command := header[0]>>4, header[0]&0x0f
+	if version != 2 || command > 1 {
+		return nil, errInvalidProxyHeader
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[2:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	// LOCAL command: the connection was established by the proxy itself.
+	if command == 0 {
+		return nil, nil
+	}
+
+	family, transport := header[1]>>4, header[1]&0x0f
+	if transport != 1 && family != 0 {
+		// Only stream transports are expected on a TCP listener.
+		return nil, errInvalidProxyHeader
+	}
+	switch family {
+	case 1: // AF_INET
+		if len(payload) < 12 {
+			return nil, errInvalidProxyHeader
+		}
+		ip := netip.AddrFrom4([4]byte(payload[:4]))
+		port := binary.BigEndian.Uint16(payload[8:])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), nil
+	case 2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, errInvalidProxyHeader
+		}
+		ip := netip.AddrFrom16([16]byte(payload[:16]))
+		port := binary.BigEndian.Uint16(payload[32:])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), nil
+	case 3: // AF_UNIX
+		if len(payload) < 216 {
+			return nil, errInvalidProxyHeader
+		}
+		name, _, _ := bytes.Cut(payload[:108], []byte{0})
+		return &net.UnixAddr{Name: string(name), Net: "unix"}, nil
+	default: // AF_UNSPEC
+		return nil, nil
+	}
+}
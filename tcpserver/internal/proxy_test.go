@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopherd/components/tcpserver"
+)
+
+// proxyV2Header builds a PROXY protocol v2 header.
+func proxyV2Header(command, family byte, payload []byte) []byte {
+	var b bytes.Buffer
+	b.Write(proxyV2Signature)
+	b.WriteByte(0x20 | command)
+	b.WriteByte(family)
+	binary.Write(&b, binary.BigEndian, uint16(len(payload)))
+	b.Write(payload)
+	return b.Bytes()
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	ipv4 := []byte{192, 0, 2, 1, 10, 0, 0, 1, 0x30, 0x39, 0x00, 0x50}
+	ipv6 := make([]byte, 36)
+	copy(ipv6, net.ParseIP("2001:db8::1"))
+	binary.BigEndian.PutUint16(ipv6[32:], 443)
+
+	tests := []struct {
+		name     string
+		header   []byte
+		expected string // Expected remote address, empty if none
+		wantErr  bool
+	}{
+		{"v1 TCP4", []byte("PROXY TCP4 192.0.2.1 10.0.0.1 12345 80\r\n"), "192.0.2.1:12345", false},
+		{"v1 TCP6", []byte("PROXY TCP6 2001:db8::1 2001:db8::2 443 80\r\n"), "[2001:db8::1]:443", false},
+		{"v1 UNKNOWN", []byte("PROXY UNKNOWN\r\n"), "", false},
+		{"v1 mismatched family", []byte("PROXY TCP4 2001:db8::1 10.0.0.1 1 2\r\n"), "", true},
+		{"v1 invalid port", []byte("PROXY TCP4 192.0.2.1 10.0.0.1 123456 80\r\n"), "", true},
+		{"v1 too long", []byte("PROXY " + strings.Repeat("x", 200) + "\r\n"), "", true},
+		{"v2 IPv4", proxyV2Header(1, 0x11, ipv4), "192.0.2.1:12345", false},
+		{"v2 IPv6", proxyV2Header(1, 0x21, ipv6), "[2001:db8::1]:443", false},
+		{"v2 LOCAL", proxyV2Header(0, 0x00, nil), "", false},
+		{"v2 short payload", proxyV2Header(1, 0x11, ipv4[:8]), "", true},
+		{"Not a header", []byte("GET / HTTP/1.1\r\n\r\n"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader(append(tt.header, "data"...))
+			addr, err := readProxyHeader(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var got string
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tt.expected {
+				t.Errorf("Expected address %q, but got %q", tt.expected, got)
+			}
+			if rest, _ := bufio.NewReader(r).ReadString(0); rest != "data" {
+				t.Errorf("Expected remaining data %q, but got %q", "data", rest)
+			}
+		})
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	tests := []struct {
+		name     string
+		trusted  []string
+		expected string
+	}{
+		{"Trusted", []string{"127.0.0.0/8"}, "192.0.2.1:12345"},
+		{"Untrusted", []string{"10.0.0.0/8"}, "127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mustNew(t, tcpserver.Options{
+				Addr:              "127.0.0.1:0",
+				ProxyProtocol:     true,
+				ProxyTrustedCIDRs: tt.trusted,
+			})
+			c.SetConnHandler(tcpserver.ConnHandlerFunc(func(ctx context.Context, conn net.Conn) {
+				conn.Write([]byte(conn.RemoteAddr().String() + "\n"))
+			}))
+			mustStart(t, c)
+
+			conn, err := net.Dial("tcp", c.listeners[0].Addr().String())
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			conn.Write([]byte("PROXY TCP4 192.0.2.1 10.0.0.1 12345 80\r\n"))
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				t.Fatalf("Failed to read: %v", err)
+			}
+			if !strings.HasPrefix(line, tt.expected) {
+				t.Errorf("Expected remote address %q, but got %q", tt.expected, line)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolRequiresTrustedCIDRs(t *testing.T) {
+	tests := []tcpserver.Options{
+		{Addr: "127.0.0.1:0", ProxyProtocol: true},
+		{Listeners: []tcpserver.ListenerOptions{
+			{Network: "tcp", Addr: "127.0.0.1:0"},
+			{Network: "tcp", Addr: "127.0.0.1:0", ProxyProtocol: true},
+		}},
+	}
+	for i, options := range tests {
+		c := mustNew(t, options)
+		if err := c.Init(context.Background()); err == nil {
+			t.Errorf("Test %d: expected an error, but got nil", i)
+		}
+	}
+}
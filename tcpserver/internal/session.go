@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gopherd/components/tcpserver"
+)
+
+// Ensure session implements tcpserver.Session interface.
+var _ tcpserver.Session = (*session)(nil)
+
+// session implements tcpserver.Session on top of a net.Conn.
+type session struct {
+	id           uint64
+	conn         net.Conn
+	codec        codec
+	handler      tcpserver.SessionHandler
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	packetSize   int // Maximum size of a received packet, 0 for stream connections
+
+	sendq  chan []byte
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error // The first error that caused the session to close
+}
+
+// ID implements tcpserver.Session.ID.
+func (s *session) ID() uint64 {
+	return s.id
+}
+
+// LocalAddr implements tcpserver.Session.LocalAddr.
+func (s *session) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// RemoteAddr implements tcpserver.Session.RemoteAddr.
+func (s *session) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// Context implements tcpserver.Session.Context.
+func (s *session) Context() context.Context {
+	return s.ctx
+}
+
+// Send implements tcpserver.Session.Send.
+func (s *session) Send(frame []byte) error {
+	if s.ctx.Err() != nil {
+		return tcpserver.ErrSessionClosed
+	}
+	if err := s.codec.checkFrame(frame); err != nil {
+		return err
+	}
+	select {
+	case s.sendq <- frame:
+		return nil
+	default:
+		return tcpserver.ErrSendQueueFull
+	}
+}
+
+// Close implements tcpserver.Session.Close.
+func (s *session) Close() error {
+	s.close(nil)
+	return nil
+}
+
+// close records the first error and signals the session to stop.
+func (s *session) close(err error) {
+	s.mu.Lock()
+	if s.ctx.Err() == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
+
+// serve runs the session until it is closed. The read loop runs on the
+// calling goroutine while frames are written on a separate goroutine.
+func (s *session) serve() {
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		s.writeLoop()
+	}()
+
+	s.handler.OnOpen(s)
+	s.readLoop()
+	<-writerDone
+
+	s.mu.Lock()
+	err := s.err
+	s.mu.Unlock()
+	s.handler.OnClose(s, err)
+}
+
+func (s *session) readLoop() {
+	var src io.Reader = s.conn
+	if s.packetSize > 0 {
+		src = &packetReader{conn: s.conn, buf: make([]byte, s.packetSize+1)}
+	}
+	r := bufio.NewReader(src)
+	for {
+		if s.readTimeout > 0 {
+			s.conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+		}
+		frame, err := s.codec.readFrame(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) || s.ctx.Err() != nil {
+				err = nil
+			}
+			s.close(err)
+			return
+		}
+		s.handler.OnFrame(s, frame)
+	}
+}
+
+func (s *session) writeLoop() {
+	// Closing the connection unblocks the read loop.
+	defer s.conn.Close()
+
+	w := bufio.NewWriter(s.conn)
+	for {
+		select {
+		case frame := <-s.sendq:
+			if err := s.write(w, frame); err != nil {
+				s.close(err)
+				return
+			}
+		case <-s.ctx.Done():
+			// Write the frames queued before the session was closed.
+			for {
+				select {
+				case frame := <-s.sendq:
+					if s.write(w, frame) != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write writes the frame and flushes if no more frames are queued.
+func (s *session) write(w *bufio.Writer, frame []byte) error {
+	if s.writeTimeout > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	}
+	if err := s.codec.writeFrame(w, frame); err != nil {
+		return err
+	}
+	if len(s.sendq) > 0 {
+		return nil
+	}
+	return w.Flush()
+}
+
+// packetReader reads whole packets from a packet-oriented connection, such as
+// unixpacket, which discards the part of a packet not fitting in the read buffer.
+type packetReader struct {
+	conn net.Conn
+	buf  []byte // One byte larger than the maximum packet size to detect truncation
+	r, w int
+}
+
+// Read implements io.Reader Read method
+func (p *packetReader) Read(b []byte) (int, error) {
+	if p.r == p.w {
+		n, err := p.conn.Read(p.buf)
+		if n == len(p.buf) {
+			return 0, tcpserver.ErrFrameTooLarge
+		}
+		if n == 0 {
+			return 0, err
+		}
+		p.r, p.w = 0, n
+	}
+	n := copy(b, p.buf[p.r:p.w])
+	p.r += n
+	return n, nil
+}
@@ -1,8 +1,15 @@
 package internal
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"log/slog"
 	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gopherd/core/component"
@@ -16,9 +23,68 @@ func init() {
 	})
 }
 
+// Ensure TCPServerComponent implements tcpserver.Component interface.
+var _ tcpserver.Component = (*TCPServerComponent)(nil)
+
 type TCPServerComponent struct {
 	component.BaseComponent[tcpserver.Options]
-	listener net.Listener
+	listeners []net.Listener
+
+	ctx           context.Context    // Cancelled when the server is shutting down
+	cancel        context.CancelFunc // Cancels ctx
+	sessions      context.Context    // Cancelled when the active sessions are force-closed
+	closeSessions context.CancelFunc // Cancels sessions
+	codec         codec              // Framing codec for sessions
+	nextID        atomic.Uint64      // Last allocated session ID
+
+	filter       *ipFilter      // Peer filter by CIDR lists
+	proxyTrusted []netip.Prefix // Upstream proxies trusted to send PROXY protocol headers
+	limiter      *tokenBucket   // Accept rate limiter, nil if unlimited
+	accepted     atomic.Int64   // Number of accepted connections
+	rejected     atomic.Int64   // Number of rejected connections
+	acceptErrors atomic.Int64   // Number of accept failures
+	forceClosed  atomic.Int64   // Number of connections closed by Shutdown before their handlers returned
+
+	serveErr chan error     // Receives the errors returned by serve for each listener
+	wg       sync.WaitGroup // Tracks connection goroutines
+
+	mu      sync.Mutex
+	handler tcpserver.ConnHandler
+	conns   map[net.Conn]netip.Addr // Active connections with peer IPs
+	ipConns map[netip.Addr]int      // Number of active connections per peer IP
+	closed  bool                    // Whether new connections are refused
+}
+
+// Init implements component.Component.Init.
+func (c *TCPServerComponent) Init(ctx context.Context) error {
+	options := c.Options()
+	codec, err := newCodec(options.Framing, options.MaxFrameSize)
+	if err != nil {
+		return err
+	}
+	c.codec = codec
+	if c.filter, err = newIPFilter(options.AllowCIDRs, options.DenyCIDRs); err != nil {
+		return err
+	}
+	if c.proxyTrusted, err = parsePrefixes(options.ProxyTrustedCIDRs); err != nil {
+		return err
+	}
+	if len(c.proxyTrusted) == 0 {
+		// Any client could spoof its address to bypass the per-IP limits and filters.
+		for _, spec := range c.listenerOptions() {
+			if spec.ProxyProtocol {
+				return errors.New("tcpserver: ProxyTrustedCIDRs is required if ProxyProtocol is enabled")
+			}
+		}
+	}
+	if options.AcceptRate > 0 {
+		c.limiter = newTokenBucket(options.AcceptRate, options.AcceptBurst)
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.sessions, c.closeSessions = context.WithCancel(context.Background())
+	c.conns = make(map[net.Conn]netip.Addr)
+	c.ipConns = make(map[netip.Addr]int)
+	return nil
 }
 
 func (c *TCPServerComponent) Start(ctx context.Context) error {
@@ -26,91 +92,338 @@ func (c *TCPServerComponent) Start(ctx context.Context) error {
 	if err := c.listen(); err != nil {
 		return err
 	}
-	go c.serve()
+	c.serveErr = make(chan error, len(c.listeners))
+	for _, l := range c.listeners {
+		c.Logger().Info("tcp server listening", "network", l.Addr().Network(), "addr", l.Addr().String())
+		go func() {
+			err := c.serve(l)
+			if !errors.Is(err, net.ErrClosed) {
+				c.Logger().Error("tcp server stopped accepting", "addr", l.Addr().String(), "error", err)
+			}
+			c.serveErr <- err
+		}()
+	}
 	return nil
 }
 
+// Shutdown stops accepting connections and closes the active connections.
+// In graceful mode, active connections are given until ctx is done to finish,
+// or ShutdownTimeout if ctx has no deadline.
 func (server *TCPServerComponent) Shutdown(ctx context.Context) error {
-	return server.listener.Close()
-}
+	var errs []error
+	for _, l := range server.listeners {
+		errs = append(errs, l.Close())
+	}
+	server.mu.Lock()
+	server.closed = true
+	server.mu.Unlock()
+	for range server.listeners {
+		if err := <-server.serveErr; !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	err := errors.Join(errs...)
 
-// listen creates a tcp server
-func (c *TCPServerComponent) listen() error {
-	options := c.Options()
-	a, err := net.ResolveTCPAddr("tcp", options.Addr)
-	if err == nil {
-		c.listener, err = net.ListenTCP("tcp", a)
+	// Notify handlers that the server is shutting down.
+	server.cancel()
+	options := server.Options()
+	graceful := options.GracefulShutdown
+	if _, ok := ctx.Deadline(); graceful && !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cmp.Or(options.ShutdownTimeout, 30))*time.Second)
+		defer cancel()
 	}
-	if err != nil {
+	if graceful && server.wait(ctx) {
 		return err
 	}
-	if options.KeepAlive > 0 {
-		if l, ok := c.listener.(*net.TCPListener); ok {
-			c.listener = newTCPKeepAliveListener(l, time.Duration(options.KeepAlive)*time.Second)
+	if n := server.closeConns(); n > 0 {
+		server.forceClosed.Add(int64(n))
+		if graceful {
+			server.Logger().Warn("force-closed connections after graceful shutdown timeout", "count", n)
 		} else {
-			c.Logger().Warn("keepalive is not supported", "addr", options.Addr)
+			server.Logger().Info("closed active connections", "count", n)
 		}
 	}
-	return nil
+
+	// Handlers return soon after their connections are closed, so give them
+	// a short while even if ctx is already done.
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), forceCloseTimeout)
+		defer cancel()
+	}
+	if !server.wait(ctx) {
+		server.Logger().Warn("connection handlers did not return after their connections were closed")
+	}
+	return err
+}
+
+// forceCloseTimeout is how long Shutdown waits for the handlers to return
+// after their connections are force-closed past the shutdown deadline.
+const forceCloseTimeout = time.Second
+
+// wait waits for all connection goroutines to finish. It returns false if
+// ctx is done before that.
+func (server *TCPServerComponent) wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		server.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// closeConns closes all active sessions and connections and returns the number of them.
+func (server *TCPServerComponent) closeConns() int {
+	server.closeSessions()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for conn := range server.conns {
+		conn.Close()
+	}
+	return len(server.conns)
 }
 
-func (server *TCPServerComponent) serve() error {
+// SetConnHandler implements tcpserver.Component.SetConnHandler.
+func (c *TCPServerComponent) SetConnHandler(handler tcpserver.ConnHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = handler
+}
+
+// Stats implements tcpserver.Component.Stats.
+func (c *TCPServerComponent) Stats() tcpserver.Stats {
+	c.mu.Lock()
+	active := len(c.conns)
+	c.mu.Unlock()
+	return tcpserver.Stats{
+		Accepted:     c.accepted.Load(),
+		Rejected:     c.rejected.Load(),
+		AcceptErrors: c.acceptErrors.Load(),
+		ForceClosed:  c.forceClosed.Load(),
+		Active:       active,
+	}
+}
+
+// SetSessionHandler implements tcpserver.Component.SetSessionHandler.
+func (c *TCPServerComponent) SetSessionHandler(handler tcpserver.SessionHandler) {
+	c.SetConnHandler(sessionConnHandler{server: c, handler: handler})
+}
+
+// newSession creates a session for the connection. The session is not closed
+// when the server starts shutting down, but only when it is force-closed.
+func (c *TCPServerComponent) newSession(conn net.Conn, handler tcpserver.SessionHandler) *session {
+	options := c.Options()
+	s := &session{
+		id:           c.nextID.Add(1),
+		conn:         conn,
+		codec:        c.codec,
+		handler:      handler,
+		readTimeout:  time.Duration(cmp.Or(options.ReadTimeout, 10)) * time.Second,
+		writeTimeout: time.Duration(cmp.Or(options.WriteTimeout, 10)) * time.Second,
+		sendq:        make(chan []byte, cmp.Or(options.SendQueueSize, 256)),
+	}
+	if conn.LocalAddr().Network() == "unixpacket" {
+		// Whole packets are read at once, so a packet must not exceed one frame.
+		s.packetSize = c.maxFrameSize() + maxFrameOverhead
+	}
+	s.ctx, s.cancel = context.WithCancel(c.sessions)
+	return s
+}
+
+// maxFrameSize returns the maximum frame size, or the default if not configured.
+func (c *TCPServerComponent) maxFrameSize() int {
+	if n := c.Options().MaxFrameSize; n > 0 {
+		return n
+	}
+	return defaultMaxFrameSize
+}
+
+// sessionConnHandler adapts a tcpserver.SessionHandler to tcpserver.ConnHandler.
+type sessionConnHandler struct {
+	server  *TCPServerComponent
+	handler tcpserver.SessionHandler
+}
+
+// ServeConn implements tcpserver.ConnHandler.ServeConn.
+func (h sessionConnHandler) ServeConn(ctx context.Context, conn net.Conn) {
+	s := h.server.newSession(conn, h.handler)
+	if sh, ok := h.handler.(tcpserver.ShutdownHandler); ok {
+		stop := context.AfterFunc(ctx, func() { sh.OnShutdown(s) })
+		defer stop()
+	}
+	s.serve()
+}
+
+// serve accepts connections from the listener until it is closed or fails
+// permanently. Transient accept failures are retried with exponential backoff.
+func (server *TCPServerComponent) serve(l net.Listener) error {
 	var tempDelay time.Duration // how long to sleep on accept failure
 	for {
-		conn, err := server.listener.Accept()
+		conn, err := l.Accept()
 		if err != nil {
-			if ne, ok := err.(net.Error); ok && ne.Temporary() {
-				if tempDelay == 0 {
-					tempDelay = 5 * time.Millisecond
-				} else {
-					tempDelay *= 2
-				}
-				if max := 1 * time.Second; tempDelay > max {
-					tempDelay = max
-				}
-				println("accept connection error: " + err.Error() + ", retrying")
-				time.Sleep(tempDelay)
-				continue
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			server.acceptErrors.Add(1)
+			class := acceptErrorClass(err)
+			if class == acceptErrorOther {
+				return err
+			}
+			if tempDelay == 0 {
+				tempDelay = 5 * time.Millisecond
+			} else {
+				tempDelay *= 2
+			}
+			if max := 1 * time.Second; tempDelay > max {
+				tempDelay = max
 			}
-			return err
+			server.Logger().Warn(
+				"accept connection failed, retrying",
+				slog.String("addr", l.Addr().String()),
+				slog.String("class", class),
+				slog.Duration("backoff", tempDelay),
+				slog.Any("error", err),
+			)
+			time.Sleep(tempDelay)
+			continue
 		}
 		tempDelay = 0
-		var ip string
-		if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
-			ip = addr.IP.String()
-		}
-		server.handle(ip, conn)
+		server.accepted.Add(1)
+		server.handle(conn)
+	}
+}
+
+// acceptErrorOther is the class of accept errors which are not retried.
+const acceptErrorOther = "other"
+
+// acceptErrorClass classifies the accept error for logging. Errors of
+// all classes but acceptErrorOther are transient.
+func acceptErrorClass(err error) string {
+	var ne net.Error
+	switch {
+	case errors.As(err, &ne) && ne.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.EMFILE), errors.Is(err, syscall.ENFILE):
+		return "too many open files"
+	case errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.ECONNRESET):
+		return "connection aborted"
+	case errors.Is(err, syscall.ENOBUFS), errors.Is(err, syscall.ENOMEM):
+		return "out of memory"
+	default:
+		return acceptErrorOther
 	}
 }
 
-func (server *TCPServerComponent) handle(ip string, conn net.Conn) {
-	// TODO: handle connection
+// reasonNoHandler is the rejection reason when no connection handler is set.
+const reasonNoHandler = "no handler"
+
+// handle hands the connection over to the registered handler on a new goroutine.
+// The connection is closed immediately if it is rejected.
+func (server *TCPServerComponent) handle(conn net.Conn) {
+	handler, reason := server.admit(conn)
+	if reason != "" {
+		// The remote address is not resolved here since reading the
+		// PROXY protocol header would block the accept loop.
+		server.reject(conn, netip.Addr{}, reason)
+		return
+	}
+	if handler == nil {
+		conn.Close()
+		return
+	}
+
+	go func() {
+		defer server.wg.Done()
+		defer server.release(conn)
+		// The client address is known after the PROXY protocol header is read.
+		if err := readProxyConnHeader(conn); err != nil {
+			server.reject(conn, netip.Addr{}, "invalid proxy header")
+			return
+		}
+		ip := remoteIP(conn.RemoteAddr())
+		if reason := server.admitIP(conn, ip); reason != "" {
+			server.reject(conn, ip, reason)
+			return
+		}
+		handler.ServeConn(server.ctx, conn)
+	}()
 }
 
-// tcpKeepAliveListener wraps TCPListener with a keepalive duration
-type tcpKeepAliveListener struct {
-	*net.TCPListener
-	duration time.Duration
+// reject counts the rejected connection and closes it.
+func (server *TCPServerComponent) reject(conn net.Conn, ip netip.Addr, reason string) {
+	server.rejected.Add(1)
+	level := slog.LevelDebug
+	if reason == reasonNoHandler {
+		level = slog.LevelWarn
+	}
+	server.Logger().Log(server.ctx, level, "connection rejected", "ip", ip, "reason", reason)
+	server.release(conn)
 }
 
-// newTCPKeepAliveListener creates a TCPKeepAliveListener
-func newTCPKeepAliveListener(ln *net.TCPListener, d time.Duration) *tcpKeepAliveListener {
-	return &tcpKeepAliveListener{
-		TCPListener: ln,
-		duration:    d,
+// admit checks the connection against the server-wide limits and starts tracking it if accepted.
+// It returns the rejection reason if rejected, or a nil handler if the server is closed.
+func (server *TCPServerComponent) admit(conn net.Conn) (tcpserver.ConnHandler, string) {
+	options := server.Options()
+	if server.limiter != nil && !server.limiter.allow(time.Now()) {
+		return nil, "rate limited"
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.closed {
+		return nil, ""
 	}
+	if server.handler == nil {
+		return nil, reasonNoHandler
+	}
+	if options.MaxConns > 0 && len(server.conns) >= options.MaxConns {
+		return nil, "too many connections"
+	}
+	server.conns[conn] = netip.Addr{}
+	server.wg.Add(1)
+	return server.handler, ""
 }
 
-// Accept implements net.Listener Accept method
-func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
-	tc, err := ln.AcceptTCP()
-	if err != nil {
-		return
+// admitIP checks the tracked connection against the per-IP filters and limits.
+// It returns the rejection reason if rejected.
+func (server *TCPServerComponent) admitIP(conn net.Conn, ip netip.Addr) string {
+	if !ip.IsValid() {
+		return ""
+	}
+	if !server.filter.allowed(ip) {
+		return "denied"
 	}
-	tc.SetKeepAlive(true)
-	if ln.duration == 0 {
-		ln.duration = 3 * time.Minute
+	maxConnsPerIP := server.Options().MaxConnsPerIP
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if maxConnsPerIP > 0 && server.ipConns[ip] >= maxConnsPerIP {
+		return "too many connections per IP"
+	}
+	server.conns[conn] = ip
+	server.ipConns[ip]++
+	return ""
+}
+
+// release stops tracking the connection and closes it.
+func (server *TCPServerComponent) release(conn net.Conn) {
+	server.mu.Lock()
+	if ip, ok := server.conns[conn]; ok {
+		delete(server.conns, conn)
+		if ip.IsValid() {
+			if n := server.ipConns[ip] - 1; n > 0 {
+				server.ipConns[ip] = n
+			} else {
+				delete(server.ipConns, ip)
+			}
+		}
 	}
-	tc.SetKeepAlivePeriod(ln.duration)
-	return tc, nil
+	server.mu.Unlock()
+	conn.Close()
 }
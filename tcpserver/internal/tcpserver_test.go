@@ -0,0 +1,625 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gopherd/core/component"
+	"github.com/gopherd/core/op"
+	"github.com/gopherd/core/typing"
+
+	"github.com/gopherd/components/tcpserver"
+)
+
+type mockEntity struct{}
+
+func (mockEntity) GetComponent(uuid string) component.Component {
+	return nil
+}
+
+func (mockEntity) Logger() *slog.Logger {
+	return slog.Default()
+}
+
+func mustNew(t *testing.T, options tcpserver.Options) *TCPServerComponent {
+	t.Helper()
+	comp, err := component.Create(tcpserver.Name)
+	if err != nil {
+		t.Fatalf("Failed to create component %q: %v", tcpserver.Name, err)
+	}
+	if err := comp.Setup(mockEntity{}, &component.Config{
+		Name:    tcpserver.Name,
+		Options: typing.NewRawObject(op.MustResult(json.Marshal(options))),
+	}, false); err != nil {
+		t.Fatalf("Failed to setup component %q: %v", tcpserver.Name, err)
+	}
+	return comp.(*TCPServerComponent)
+}
+
+// mustStart initializes and starts the component, and registers cleanup.
+func mustStart(t *testing.T, c *TCPServerComponent) {
+	t.Helper()
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start component: %v", err)
+	}
+	t.Cleanup(func() {
+		c.Shutdown(context.Background())
+		c.Uninit(context.Background())
+	})
+}
+
+func TestConnHandler(t *testing.T) {
+	c := mustNew(t, tcpserver.Options{Addr: "127.0.0.1:0"})
+	c.SetConnHandler(tcpserver.ConnHandlerFunc(func(ctx context.Context, conn net.Conn) {
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return
+		}
+		conn.Write([]byte(line))
+	}))
+	mustStart(t, c)
+
+	conn, err := net.Dial("tcp", c.listeners[0].Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if line != "hello\n" {
+		t.Errorf("Expected %q, but got %q", "hello\n", line)
+	}
+}
+
+func TestShutdownClosesConns(t *testing.T) {
+	c := mustNew(t, tcpserver.Options{Addr: "127.0.0.1:0"})
+	served := make(chan struct{})
+	done := make(chan struct{})
+	c.SetConnHandler(tcpserver.ConnHandlerFunc(func(ctx context.Context, conn net.Conn) {
+		close(served)
+		var buf [1]byte
+		conn.Read(buf[:])
+		close(done)
+	}))
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start component: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", c.listeners[0].Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connection was not served")
+	}
+	c.Shutdown(context.Background())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connection was not closed on shutdown")
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket file permissions are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "test.sock")
+
+	// Leave a stale socket file behind.
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	c := mustNew(t, tcpserver.Options{Network: "unix", Addr: path, UnixSocketPerm: "0600"})
+	c.SetConnHandler(tcpserver.ConnHandlerFunc(func(ctx context.Context, conn net.Conn) {
+		conn.Write([]byte("hello\n"))
+	}))
+	mustStart(t, c)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat socket file: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != fs.FileMode(0600) {
+		t.Errorf("Expected socket file perm %v, but got %v", fs.FileMode(0600), perm)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if line != "hello\n" {
+		t.Errorf("Expected %q, but got %q", "hello\n", line)
+	}
+
+	// A socket in use must not be removed.
+	other := mustNew(t, tcpserver.Options{Network: "unix", Addr: path})
+	if err := other.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	if err := other.Start(context.Background()); err == nil {
+		other.Shutdown(context.Background())
+		t.Error("Expected an error when the socket is in use, but got nil")
+	}
+}
+
+func TestUnsupportedNetwork(t *testing.T) {
+	c := mustNew(t, tcpserver.Options{Network: "udp", Addr: "127.0.0.1:0"})
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	if err := c.Start(context.Background()); err == nil {
+		c.Shutdown(context.Background())
+		t.Error("Expected an error, but got nil")
+	}
+}
+
+// echoSessionHandler echoes frames back and records closed sessions.
+type echoSessionHandler struct {
+	closed chan error
+}
+
+func (h *echoSessionHandler) OnOpen(s tcpserver.Session) {}
+
+func (h *echoSessionHandler) OnFrame(s tcpserver.Session, frame []byte) {
+	s.Send(frame)
+}
+
+func (h *echoSessionHandler) OnClose(s tcpserver.Session, err error) {
+	h.closed <- err
+}
+
+func TestSessionHandler(t *testing.T) {
+	c := mustNew(t, tcpserver.Options{Addr: "127.0.0.1:0", Framing: "line"})
+	h := &echoSessionHandler{closed: make(chan error, 1)}
+	c.SetSessionHandler(h)
+	mustStart(t, c)
+
+	conn, err := net.Dial("tcp", c.listeners[0].Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("ping\r\npong\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"ping\n", "pong\n"} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		if line != want {
+			t.Errorf("Expected %q, but got %q", want, line)
+		}
+	}
+
+	conn.Close()
+	select {
+	case err := <-h.closed:
+		if err != nil {
+			t.Errorf("Unexpected close error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Session was not closed")
+	}
+}
+
+// invalidFrameHandler sends an oversized frame and a frame containing the
+// delimiter before echoing.
+type invalidFrameHandler struct {
+	echoSessionHandler
+	sent chan error
+}
+
+func (h *invalidFrameHandler) OnOpen(s tcpserver.Session) {
+	h.sent <- s.Send(make([]byte, 64))
+	h.sent <- s.Send([]byte("a\nb"))
+}
+
+func TestSessionSendInvalidFrame(t *testing.T) {
+	c := mustNew(t, tcpserver.Options{Addr: "127.0.0.1:0", Framing: "line", MaxFrameSize: 16})
+	h := &invalidFrameHandler{
+		echoSessionHandler: echoSessionHandler{closed: make(chan error, 1)},
+		sent:               make(chan error, 2),
+	}
+	c.SetSessionHandler(h)
+	mustStart(t, c)
+
+	conn, err := net.Dial("tcp", c.listeners[0].Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []error{tcpserver.ErrFrameTooLarge, tcpserver.ErrInvalidFrame} {
+		select {
+		case err := <-h.sent:
+			if !errors.Is(err, want) {
+				t.Errorf("Expected %v, but got %v", want, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Session was not opened")
+		}
+	}
+
+	// The session must stay usable after the rejected frames.
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if line != "ping\n" {
+		t.Errorf("Expected %q, but got %q", "ping\n", line)
+	}
+}
+
+func TestUnixPacketSession(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("unixpacket is only tested on linux")
+	}
+	path := filepath.Join(t.TempDir(), "test.sock")
+	c := mustNew(t, tcpserver.Options{Network: "unixpacket", Addr: path})
+	h := &echoSessionHandler{closed: make(chan error, 1)}
+	c.SetSessionHandler(h)
+	mustStart(t, c)
+
+	conn, err := net.Dial("unixpacket", path)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// A frame larger than the default read buffer is sent in one packet.
+	frame := bytes.Repeat([]byte("x"), 8000)
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(frame)))
+	if _, err := conn.Write(append(packet, frame...)); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	var got []byte
+	buf := make([]byte, 64*1024)
+	for len(got) < len(packet)+len(frame) {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got[4:], frame) {
+		t.Errorf("Expected the frame of %d bytes echoed, but got %d bytes", len(frame), len(got)-4)
+	}
+}
+
+func TestMaxConnsPerIP(t *testing.T) {
+	c := mustNew(t, tcpserver.Options{Addr: "127.0.0.1:0", MaxConnsPerIP: 1})
+	c.SetConnHandler(tcpserver.ConnHandlerFunc(func(ctx context.Context, conn net.Conn) {
+		conn.Write([]byte("hello\n"))
+		var buf [1]byte
+		conn.Read(buf[:])
+	}))
+	mustStart(t, c)
+	addr := c.listeners[0].Addr().String()
+
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer first.Close()
+	first.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := bufio.NewReader(first).ReadString('\n'); err != nil {
+		t.Fatalf("Failed to read from first connection: %v", err)
+	}
+
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer second.Close()
+	second.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := bufio.NewReader(second).ReadString('\n'); err == nil {
+		t.Error("Expected second connection to be rejected")
+	}
+	stats := c.Stats()
+	if stats.Accepted != 2 || stats.Rejected != 1 || stats.Active != 1 {
+		t.Errorf("Expected 2 accepted, 1 rejected and 1 active connections, but got %+v", stats)
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	c := mustNew(t, tcpserver.Options{Addr: "127.0.0.1:0", GracefulShutdown: true})
+	served := make(chan struct{}, 2)
+	c.SetConnHandler(tcpserver.ConnHandlerFunc(func(ctx context.Context, conn net.Conn) {
+		served <- struct{}{}
+		var buf [1]byte
+		if _, err := conn.Read(buf[:]); err != nil {
+			return
+		}
+		// The client asks to finish on shutdown.
+		<-ctx.Done()
+		conn.Write([]byte("bye\n"))
+	}))
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start component: %v", err)
+	}
+	addr := c.listeners[0].Addr().String()
+
+	polite, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer polite.Close()
+	polite.SetDeadline(time.Now().Add(5 * time.Second))
+	polite.Write([]byte("x"))
+
+	stubborn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer stubborn.Close()
+	stubborn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-served:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Connection was not served")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := c.Shutdown(ctx); err != nil {
+		t.Errorf("Unexpected error during Shutdown: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected Shutdown to wait for the stubborn connection, but returned after %v", elapsed)
+	}
+
+	line, err := bufio.NewReader(polite).ReadString('\n')
+	if err != nil || line != "bye\n" {
+		t.Errorf("Expected polite connection to finish with %q, but got %q, %v", "bye\n", line, err)
+	}
+	var buf [1]byte
+	if _, err := stubborn.Read(buf[:]); err == nil {
+		t.Error("Expected stubborn connection to be force-closed")
+	}
+	if n := c.Stats().ForceClosed; n != 1 {
+		t.Errorf("Expected 1 force-closed connection, but got %d", n)
+	}
+}
+
+// shutdownSessionHandler says goodbye and closes the session on shutdown.
+type shutdownSessionHandler struct {
+	echoSessionHandler
+}
+
+func (h *shutdownSessionHandler) OnShutdown(s tcpserver.Session) {
+	s.Send([]byte("bye"))
+	s.Close()
+}
+
+func TestGracefulShutdownSessions(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     tcpserver.SessionHandler
+		forceClosed int64
+	}{
+		{"echo", &echoSessionHandler{closed: make(chan error, 1)}, 1},
+		{"shutdown", &shutdownSessionHandler{echoSessionHandler{closed: make(chan error, 1)}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mustNew(t, tcpserver.Options{Addr: "127.0.0.1:0", Framing: "line", GracefulShutdown: true})
+			c.SetSessionHandler(tt.handler)
+			if err := c.Init(context.Background()); err != nil {
+				t.Fatalf("Failed to init component: %v", err)
+			}
+			if err := c.Start(context.Background()); err != nil {
+				t.Fatalf("Failed to start component: %v", err)
+			}
+
+			conn, err := net.Dial("tcp", c.listeners[0].Addr().String())
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			r := bufio.NewReader(conn)
+			// Make sure the session is open before shutting down.
+			conn.Write([]byte("hello\n"))
+			if _, err := r.ReadString('\n'); err != nil {
+				t.Fatalf("Failed to read: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			shutdown := make(chan struct{})
+			go func() {
+				defer close(shutdown)
+				c.Shutdown(ctx)
+			}()
+
+			if tt.forceClosed == 0 {
+				if line, err := r.ReadString('\n'); err != nil || line != "bye\n" {
+					t.Errorf("Expected %q, but got %q, %v", "bye\n", line, err)
+				}
+			} else {
+				// The session keeps serving while the server is draining.
+				conn.Write([]byte("ping\n"))
+				if line, err := r.ReadString('\n'); err != nil || line != "ping\n" {
+					t.Errorf("Expected %q, but got %q, %v", "ping\n", line, err)
+				}
+			}
+			<-shutdown
+			if n := c.Stats().ForceClosed; n != tt.forceClosed {
+				t.Errorf("Expected %d force-closed connections, but got %d", tt.forceClosed, n)
+			}
+		})
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	c := mustNew(t, tcpserver.Options{Addr: "127.0.0.1:0", GracefulShutdown: true, ShutdownTimeout: 1})
+	served := make(chan struct{})
+	c.SetConnHandler(tcpserver.ConnHandlerFunc(func(ctx context.Context, conn net.Conn) {
+		close(served)
+		var buf [1]byte
+		conn.Read(buf[:])
+	}))
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start component: %v", err)
+	}
+	conn, err := net.Dial("tcp", c.listeners[0].Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connection was not served")
+	}
+
+	// The idle connection is force-closed after ShutdownTimeout even if the
+	// shutdown context has no deadline.
+	start := time.Now()
+	c.Shutdown(context.Background())
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 3*time.Second {
+		t.Errorf("Expected Shutdown to return after about 1s, but returned after %v", elapsed)
+	}
+	if n := c.Stats().ForceClosed; n != 1 {
+		t.Errorf("Expected 1 force-closed connection, but got %d", n)
+	}
+}
+
+func TestAcceptErrorClass(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{&net.OpError{Op: "accept", Err: os.NewSyscallError("accept", syscall.EMFILE)}, "too many open files"},
+		{&net.OpError{Op: "accept", Err: os.ErrDeadlineExceeded}, "timeout"},
+		{syscall.ECONNABORTED, "connection aborted"},
+		{io.ErrUnexpectedEOF, "other"},
+	}
+	for _, tt := range tests {
+		if got := acceptErrorClass(tt.err); got != tt.expected {
+			t.Errorf("acceptErrorClass(%v) = %q, want %q", tt.err, got, tt.expected)
+		}
+	}
+}
+
+// errListener is a listener whose Accept returns the errors in order.
+type errListener struct {
+	net.Listener
+	errs []error
+}
+
+func (l *errListener) Accept() (net.Conn, error) {
+	err := l.errs[0]
+	l.errs = l.errs[1:]
+	return nil, err
+}
+
+func (l *errListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func TestServeAcceptErrors(t *testing.T) {
+	c := mustNew(t, tcpserver.Options{Addr: "127.0.0.1:0"})
+	transient := &net.OpError{Op: "accept", Err: os.NewSyscallError("accept", syscall.EMFILE)}
+	permanent := &net.OpError{Op: "accept", Err: io.ErrUnexpectedEOF}
+	l := &errListener{errs: []error{transient, permanent, net.ErrClosed}}
+
+	if err := c.serve(l); err != permanent {
+		t.Errorf("Expected serve to return %v, but got %v", permanent, err)
+	}
+	if n := c.Stats().AcceptErrors; n != 2 {
+		t.Errorf("Expected 2 accept errors, but got %d", n)
+	}
+}
+
+func TestMultipleListeners(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not tested on windows")
+	}
+	path := filepath.Join(t.TempDir(), "test.sock")
+	c := mustNew(t, tcpserver.Options{Listeners: []tcpserver.ListenerOptions{
+		{Network: "tcp", Addr: "127.0.0.1:0"},
+		{Network: "unix", Addr: path},
+	}})
+	c.SetConnHandler(tcpserver.ConnHandlerFunc(func(ctx context.Context, conn net.Conn) {
+		conn.Write([]byte(conn.LocalAddr().Network() + "\n"))
+	}))
+	mustStart(t, c)
+
+	if len(c.listeners) != 2 {
+		t.Fatalf("Expected 2 listeners, but got %d", len(c.listeners))
+	}
+	for _, l := range c.listeners {
+		addr := l.Addr()
+		conn, err := net.Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Fatalf("Failed to dial %s: %v", addr, err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		conn.Close()
+		if err != nil {
+			t.Fatalf("Failed to read from %s: %v", addr, err)
+		}
+		if line != addr.Network()+"\n" {
+			t.Errorf("Expected %q, but got %q", addr.Network()+"\n", line)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gopherd/components/tcpserver"
+)
+
+// tlsReloadInterval is the minimum interval between checks for changed certificate files.
+var tlsReloadInterval = time.Second
+
+// tlsReloader builds the TLS configuration from certificate files and
+// reloads it when the files change on disk.
+type tlsReloader struct {
+	options    tcpserver.TLSOptions
+	minVersion uint16
+	logger     *slog.Logger
+
+	mu       sync.Mutex
+	checked  time.Time   // Last time the files were checked
+	modTimes []time.Time // Modification times of the loaded files
+	config   *tls.Config // Current configuration
+}
+
+// newTLSConfig creates a TLS configuration which reloads the certificate
+// files when they change.
+func newTLSConfig(options tcpserver.TLSOptions, logger *slog.Logger) (*tls.Config, error) {
+	if options.CertFile == "" || options.KeyFile == "" {
+		return nil, errors.New("tcpserver: both TLS cert file and key file are required")
+	}
+	if options.RequireClientCert && options.ClientCAFile == "" {
+		return nil, errors.New("tcpserver: TLS client CA file is required to verify client certificates")
+	}
+	minVersion, err := parseTLSVersion(options.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	r := &tlsReloader{
+		options:    options,
+		minVersion: minVersion,
+		logger:     logger,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:         minVersion,
+		GetConfigForClient: r.getConfigForClient,
+	}, nil
+}
+
+// getConfigForClient returns the current configuration, reloading it if the files changed.
+func (r *tlsReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now := time.Now(); now.Sub(r.checked) >= tlsReloadInterval {
+		r.checked = now
+		if err := r.reload(); err != nil {
+			r.logger.Error("failed to reload TLS certificates", "error", err)
+		}
+	}
+	return r.config, nil
+}
+
+// files returns the certificate files to watch.
+func (r *tlsReloader) files() []string {
+	files := []string{r.options.CertFile, r.options.KeyFile}
+	if r.options.ClientCAFile != "" {
+		files = append(files, r.options.ClientCAFile)
+	}
+	return files
+}
+
+// reload loads the certificate files if any of them changed since the last load.
+// The current configuration is kept if loading fails.
+func (r *tlsReloader) reload() error {
+	files := r.files()
+	modTimes := make([]time.Time, len(files))
+	changed := r.config == nil
+	for i, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = fi.ModTime()
+		if r.modTimes == nil || !modTimes[i].Equal(r.modTimes[i]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.options.CertFile, r.options.KeyFile)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   r.minVersion,
+	}
+	if r.options.ClientCAFile != "" {
+		data, err := os.ReadFile(r.options.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("tcpserver: no certificates found in %s", r.options.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		if r.options.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		} else {
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	if r.config != nil {
+		r.logger.Info("TLS certificates reloaded", "cert", r.options.CertFile)
+	}
+	r.config = config
+	r.modTimes = modTimes
+	return nil
+}
+
+// parseTLSVersion parses the TLS version string. The default is TLS 1.2.
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("tcpserver: unsupported TLS version %q", version)
+	}
+}
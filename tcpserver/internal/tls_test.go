@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gopherd/components/tcpserver"
+)
+
+// testCert is a generated certificate with its private key.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	tls  tls.Certificate
+}
+
+// newTestCert generates a certificate signed by parent, or a self-signed CA if parent is nil.
+func newTestCert(t *testing.T, serial int64, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return &testCert{
+		cert: cert,
+		key:  key,
+		tls:  tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+	}
+}
+
+// writeFiles writes the certificate and key as PEM files and returns their paths.
+func (c *testCert) writeFiles(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatalf("Failed to write cert file: %v", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	return certFile, keyFile
+}
+
+// dialTLS dials the server and completes the handshake.
+func dialTLS(addr string, config *tls.Config) (*tls.Conn, error) {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	// With TLS 1.3, a rejected client certificate is reported on the first read.
+	var buf [1]byte
+	if _, err := conn.Read(buf[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func newTLSServer(t *testing.T, options tcpserver.TLSOptions) *TCPServerComponent {
+	t.Helper()
+	c := mustNew(t, tcpserver.Options{Addr: "127.0.0.1:0", TLS: options})
+	c.SetConnHandler(tcpserver.ConnHandlerFunc(func(ctx context.Context, conn net.Conn) {
+		conn.Write([]byte("!"))
+		var buf [1]byte
+		conn.Read(buf[:])
+	}))
+	mustStart(t, c)
+	return c
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, nil)
+	server := newTestCert(t, 2, ca)
+	certFile, keyFile := server.writeFiles(t, dir, "server")
+
+	c := newTLSServer(t, tcpserver.TLSOptions{CertFile: certFile, KeyFile: keyFile})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	conn, err := dialTLS(c.listeners[0].Addr().String(), &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	conn.Close()
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, nil)
+	server := newTestCert(t, 2, ca)
+	client := newTestCert(t, 3, ca)
+	certFile, keyFile := server.writeFiles(t, dir, "server")
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+
+	c := newTLSServer(t, tcpserver.TLSOptions{
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		ClientCAFile:      caFile,
+		RequireClientCert: true,
+	})
+	addr := c.listeners[0].Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	if conn, err := dialTLS(addr, &tls.Config{RootCAs: roots}); err == nil {
+		conn.Close()
+		t.Error("Expected an error without client certificate, but got nil")
+	}
+	conn, err := dialTLS(addr, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{client.tls}})
+	if err != nil {
+		t.Fatalf("Failed to dial with client certificate: %v", err)
+	}
+	conn.Close()
+}
+
+func TestTLSReload(t *testing.T) {
+	old := tlsReloadInterval
+	tlsReloadInterval = 0
+	defer func() { tlsReloadInterval = old }()
+
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, nil)
+	certFile, keyFile := newTestCert(t, 2, ca).writeFiles(t, dir, "server")
+
+	c := newTLSServer(t, tcpserver.TLSOptions{CertFile: certFile, KeyFile: keyFile})
+	addr := c.listeners[0].Addr().String()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	serial := func() int64 {
+		t.Helper()
+		conn, err := dialTLS(addr, &tls.Config{RootCAs: roots})
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+	if got := serial(); got != 2 {
+		t.Fatalf("Expected serial 2, but got %d", got)
+	}
+
+	newTestCert(t, 4, ca).writeFiles(t, dir, "server")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	os.Chtimes(keyFile, future, future)
+	if got := serial(); got != 4 {
+		t.Errorf("Expected serial 4 after reload, but got %d", got)
+	}
+}
+
+func TestTLSInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options tcpserver.TLSOptions
+	}{
+		{"Missing key file", tcpserver.TLSOptions{CertFile: "server.crt"}},
+		{"Missing client CA", tcpserver.TLSOptions{CertFile: "server.crt", KeyFile: "server.key", RequireClientCert: true}},
+		{"Invalid min version", tcpserver.TLSOptions{CertFile: "server.crt", KeyFile: "server.key", MinVersion: "2.0"}},
+		{"Cert file not found", tcpserver.TLSOptions{CertFile: "server.crt", KeyFile: "server.key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTLSConfig(tt.options, nil); err == nil {
+				t.Error("Expected an error, but got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package tcpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+var (
+	// ErrSessionClosed is returned when sending a frame to a closed session.
+	ErrSessionClosed = errors.New("tcpserver: session is closed")
+
+	// ErrSendQueueFull is returned when the send queue of a session is at capacity.
+	ErrSendQueueFull = errors.New("tcpserver: send queue is full")
+
+	// ErrFrameTooLarge is returned when a frame exceeds the maximum frame size.
+	ErrFrameTooLarge = errors.New("tcpserver: frame too large")
+
+	// ErrInvalidFrame is returned when sending a frame which cannot be framed,
+	// e.g. a frame containing a newline in line framing.
+	ErrInvalidFrame = errors.New("tcpserver: invalid frame")
+)
+
+// Session represents a framed connection.
+type Session interface {
+	// ID returns the unique identifier of the session.
+	ID() uint64
+	// LocalAddr returns the local network address.
+	LocalAddr() net.Addr
+	// RemoteAddr returns the remote network address.
+	RemoteAddr() net.Addr
+	// Context returns a context which is done when the session is closed.
+	Context() context.Context
+	// Send queues the frame to be written. The frame must not be modified after
+	// Send is called. It returns ErrFrameTooLarge if the frame exceeds the
+	// maximum frame size, ErrInvalidFrame if the frame cannot be framed,
+	// ErrSendQueueFull if the send queue is full, and ErrSessionClosed if the
+	// session is closed.
+	Send(frame []byte) error
+	// Close closes the session after the queued frames are written.
+	Close() error
+}
+
+// SessionHandler handles session events. Methods of a session handler
+// are called on the session's read goroutine.
+type SessionHandler interface {
+	// OnOpen is called when the session is established.
+	OnOpen(s Session)
+	// OnFrame is called for each frame received. The handler owns the frame.
+	OnFrame(s Session, frame []byte)
+	// OnClose is called when the session is closed with the error that caused it,
+	// or nil if the session was closed normally.
+	OnClose(s Session, err error)
+}
+
+// ShutdownHandler may be implemented by a SessionHandler to be notified when
+// the server is shutting down. In graceful mode, sessions are kept open until
+// they are closed or the shutdown context is done, so the handler should close
+// each session once its pending work is finished.
+type ShutdownHandler interface {
+	// OnShutdown is called for each open session when the server starts
+	// shutting down. Unlike the other methods, it is called on a separate goroutine.
+	OnShutdown(s Session)
+}
@@ -0,0 +1,17 @@
+package tcpserver
+
+// Stats represents the connection statistics of the TCP server.
+type Stats struct {
+	// Accepted is the number of connections accepted by the listeners,
+	// including the ones rejected afterwards.
+	Accepted int64
+	// Rejected is the number of connections closed immediately by limits or filters.
+	Rejected int64
+	// AcceptErrors is the number of failed accepts.
+	AcceptErrors int64
+	// ForceClosed is the number of connections closed by Shutdown before their
+	// handlers returned, e.g. after the graceful shutdown timeout.
+	ForceClosed int64
+	// Active is the number of connections currently being served.
+	Active int
+}
@@ -0,0 +1,3 @@
+package export
+
+import _ "github.com/gopherd/components/wsserver/internal"
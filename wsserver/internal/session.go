@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/gopherd/components/tcpserver"
+)
+
+// Ensure session implements tcpserver.Session interface.
+var _ tcpserver.Session = (*session)(nil)
+
+// session implements tcpserver.Session on top of a WebSocket connection.
+type session struct {
+	id           uint64
+	conn         *websocket.Conn
+	handler      tcpserver.SessionHandler
+	messageType  int
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	maxFrameSize int
+
+	sendq  chan []byte
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error // The first error that caused the session to close
+}
+
+// ID implements tcpserver.Session.ID.
+func (s *session) ID() uint64 {
+	return s.id
+}
+
+// LocalAddr implements tcpserver.Session.LocalAddr.
+func (s *session) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// RemoteAddr implements tcpserver.Session.RemoteAddr.
+func (s *session) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// Context implements tcpserver.Session.Context.
+func (s *session) Context() context.Context {
+	return s.ctx
+}
+
+// Send implements tcpserver.Session.Send.
+func (s *session) Send(frame []byte) error {
+	if s.ctx.Err() != nil {
+		return tcpserver.ErrSessionClosed
+	}
+	if len(frame) > s.maxFrameSize {
+		return tcpserver.ErrFrameTooLarge
+	}
+	select {
+	case s.sendq <- frame:
+		return nil
+	default:
+		return tcpserver.ErrSendQueueFull
+	}
+}
+
+// Close implements tcpserver.Session.Close.
+func (s *session) Close() error {
+	s.close(nil)
+	return nil
+}
+
+// close records the first error and signals the session to stop.
+func (s *session) close(err error) {
+	s.mu.Lock()
+	if s.ctx.Err() == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
+
+// serve runs the session until it is closed. The read loop runs on the
+// calling goroutine while frames are written on a separate goroutine.
+func (s *session) serve() {
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		s.writeLoop()
+	}()
+
+	s.handler.OnOpen(s)
+	s.readLoop()
+	<-writerDone
+
+	s.mu.Lock()
+	err := s.err
+	s.mu.Unlock()
+	s.handler.OnClose(s, err)
+}
+
+// pingInterval returns the interval of pings sent to keep the session alive.
+// Pings are sent often enough for the pongs to arrive before the read deadline.
+func (s *session) pingInterval() time.Duration {
+	return s.readTimeout * 9 / 10
+}
+
+func (s *session) readLoop() {
+	// The read deadline is extended by every frame and pong received, so
+	// idle peers answering pings are kept while half-open ones are dropped.
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+	})
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+		_, frame, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) || s.ctx.Err() != nil {
+				err = nil
+			} else if errors.Is(err, websocket.ErrReadLimit) {
+				err = tcpserver.ErrFrameTooLarge
+			}
+			s.close(err)
+			return
+		}
+		s.handler.OnFrame(s, frame)
+	}
+}
+
+func (s *session) writeLoop() {
+	// Closing the connection unblocks the read loop.
+	defer s.conn.Close()
+
+	ticker := time.NewTicker(s.pingInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.write(websocket.PingMessage, nil); err != nil {
+				s.close(err)
+				return
+			}
+		case frame := <-s.sendq:
+			if err := s.write(s.messageType, frame); err != nil {
+				s.close(err)
+				return
+			}
+		case <-s.ctx.Done():
+			// Write the frames queued before the session was closed.
+			for {
+				select {
+				case frame := <-s.sendq:
+					if s.write(s.messageType, frame) != nil {
+						return
+					}
+				default:
+					s.write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+					return
+				}
+			}
+		}
+	}
+}
+
+// write writes a message with the write deadline.
+func (s *session) write(messageType int, data []byte) error {
+	if s.writeTimeout > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	}
+	return s.conn.WriteMessage(messageType, data)
+}
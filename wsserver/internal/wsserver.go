@@ -0,0 +1,267 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/gopherd/core/component"
+
+	"github.com/gopherd/components/httpserver"
+	"github.com/gopherd/components/tcpserver"
+	"github.com/gopherd/components/wsserver"
+)
+
+func init() {
+	component.Register(wsserver.Name, func() component.Component {
+		return &WSServerComponent{}
+	})
+}
+
+// Ensure WSServerComponent implements wsserver.Component interface.
+var _ wsserver.Component = (*WSServerComponent)(nil)
+
+type WSServerComponent struct {
+	component.BaseComponentWithRefs[wsserver.Options, struct {
+		HTTPServer component.OptionalReference[httpserver.Component]
+	}]
+	upgrader websocket.Upgrader
+	listener net.Listener // Own listener, nil if mounted on the referenced HTTP server
+	server   *http.Server // Own HTTP server, nil if mounted on the referenced HTTP server
+
+	ctx           context.Context    // Cancelled when the server is shutting down
+	cancel        context.CancelFunc // Cancels ctx
+	sessionCtx    context.Context    // Cancelled when the active sessions are force-closed
+	closeSessions context.CancelFunc // Cancels sessionCtx
+	nextID        atomic.Uint64      // Last allocated session ID
+	wg            sync.WaitGroup     // Tracks session goroutines
+
+	mu       sync.Mutex
+	handler  tcpserver.SessionHandler
+	sessions map[*session]struct{} // Active sessions
+	closed   bool                  // Whether new connections are refused
+}
+
+// Init implements component.Component.Init.
+func (c *WSServerComponent) Init(ctx context.Context) error {
+	c.upgrader = websocket.Upgrader{
+		HandshakeTimeout: time.Duration(cmp.Or(c.Options().WriteTimeout, 10)) * time.Second,
+	}
+	if origins := c.Options().AllowedOrigins; len(origins) > 0 {
+		c.upgrader.CheckOrigin = func(r *http.Request) bool {
+			return checkOrigin(origins, r)
+		}
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.sessionCtx, c.closeSessions = context.WithCancel(context.Background())
+	c.sessions = make(map[*session]struct{})
+	return nil
+}
+
+// Start implements component.Component.Start.
+func (c *WSServerComponent) Start(ctx context.Context) error {
+	path := cmp.Or(c.Options().Path, "/ws")
+	addr := c.Options().Addr
+	if addr == "" {
+		server := c.Refs().HTTPServer.Component()
+		if server == nil {
+			return errors.New("wsserver: either addr or HTTPServer reference is required")
+		}
+		c.Logger().Info("register WebSocket handler", "path", path)
+		server.HandleFunc([]string{http.MethodGet}, path, c.serveHTTP)
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	c.listener = ln
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, c.serveHTTP)
+	c.server = &http.Server{Handler: mux}
+	c.Logger().Info("websocket server listening", "addr", ln.Addr().String(), "path", path)
+	go func() {
+		if err := c.server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			c.Logger().Error("websocket server stopped", "addr", addr, "error", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops accepting connections and closes the active sessions.
+// In graceful mode, active sessions are given until ctx is done to finish,
+// or ShutdownTimeout if ctx has no deadline.
+func (c *WSServerComponent) Shutdown(ctx context.Context) error {
+	options := c.Options()
+	if _, ok := ctx.Deadline(); options.GracefulShutdown && !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cmp.Or(options.ShutdownTimeout, 30))*time.Second)
+		defer cancel()
+	}
+	var err error
+	if c.server != nil {
+		// Hijacked connections are not tracked by the HTTP server.
+		err = c.server.Shutdown(ctx)
+	}
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	// Notify handlers that the server is shutting down.
+	c.cancel()
+	if options.GracefulShutdown && c.wait(ctx) {
+		return err
+	}
+	c.mu.Lock()
+	n := len(c.sessions)
+	c.mu.Unlock()
+	if n > 0 && options.GracefulShutdown {
+		c.Logger().Warn("force-closing sessions after graceful shutdown timeout", "count", n)
+	} else if n > 0 {
+		c.Logger().Info("closing active sessions", "count", n)
+	}
+	c.closeSessions()
+
+	// Handlers return soon after their sessions are closed, so give them
+	// a short while even if ctx is already done.
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), forceCloseTimeout)
+		defer cancel()
+	}
+	if !c.wait(ctx) {
+		c.Logger().Warn("session handlers did not return after their sessions were closed")
+	}
+	return err
+}
+
+// forceCloseTimeout is how long Shutdown waits for the handlers to return
+// after their sessions are force-closed past the shutdown deadline.
+const forceCloseTimeout = time.Second
+
+// wait waits for all session goroutines to finish. It returns false if
+// ctx is done before that.
+func (c *WSServerComponent) wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SetSessionHandler implements wsserver.Component.SetSessionHandler.
+func (c *WSServerComponent) SetSessionHandler(handler tcpserver.SessionHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = handler
+}
+
+// serveHTTP upgrades the request to a WebSocket connection and serves the session.
+func (c *WSServerComponent) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	handler, closed := c.handler, c.closed
+	c.mu.Unlock()
+	if closed {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if handler == nil {
+		c.Logger().Warn("websocket connection rejected", "remote", r.RemoteAddr, "reason", "no handler")
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	conn, err := c.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied with an HTTP error.
+		c.Logger().Debug("websocket upgrade failed", "remote", r.RemoteAddr, "error", err)
+		return
+	}
+
+	s := c.newSession(conn, handler)
+	if !c.track(s) {
+		conn.Close()
+		return
+	}
+	defer c.untrack(s)
+	if sh, ok := handler.(tcpserver.ShutdownHandler); ok {
+		stop := context.AfterFunc(c.ctx, func() { sh.OnShutdown(s) })
+		defer stop()
+	}
+	s.serve()
+}
+
+// newSession creates a session for the connection. The session is not closed
+// when the server starts shutting down, but only when it is force-closed.
+func (c *WSServerComponent) newSession(conn *websocket.Conn, handler tcpserver.SessionHandler) *session {
+	options := c.Options()
+	maxFrameSize := cmp.Or(options.MaxFrameSize, 1<<20)
+	conn.SetReadLimit(int64(maxFrameSize))
+	s := &session{
+		id:           c.nextID.Add(1),
+		conn:         conn,
+		handler:      handler,
+		messageType:  websocket.BinaryMessage,
+		readTimeout:  time.Duration(cmp.Or(options.ReadTimeout, 10)) * time.Second,
+		writeTimeout: time.Duration(cmp.Or(options.WriteTimeout, 10)) * time.Second,
+		maxFrameSize: maxFrameSize,
+		sendq:        make(chan []byte, cmp.Or(options.SendQueueSize, 256)),
+	}
+	if options.TextFrames {
+		s.messageType = websocket.TextMessage
+	}
+	s.ctx, s.cancel = context.WithCancel(c.sessionCtx)
+	return s
+}
+
+// track starts tracking the session. It returns false if the server is closed.
+func (c *WSServerComponent) track(s *session) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.sessions[s] = struct{}{}
+	c.wg.Add(1)
+	return true
+}
+
+// untrack stops tracking the session.
+func (c *WSServerComponent) untrack(s *session) {
+	c.mu.Lock()
+	delete(c.sessions, s)
+	c.mu.Unlock()
+	c.wg.Done()
+}
+
+// checkOrigin reports whether the Origin header of the request is one of origins.
+// Requests without an Origin header are not sent by browsers and are allowed.
+func checkOrigin(origins []string, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(origins, func(allowed string) bool {
+		return allowed == "*" || strings.EqualFold(allowed, u.Scheme+"://"+u.Host)
+	})
+}
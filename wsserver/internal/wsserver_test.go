@@ -0,0 +1,337 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/gopherd/core/component"
+	"github.com/gopherd/core/op"
+	"github.com/gopherd/core/typing"
+
+	"github.com/gopherd/components/tcpserver"
+	"github.com/gopherd/components/wsserver"
+)
+
+type mockEntity struct{}
+
+func (mockEntity) GetComponent(uuid string) component.Component {
+	return nil
+}
+
+func (mockEntity) Logger() *slog.Logger {
+	return slog.Default()
+}
+
+func mustNew(t *testing.T, options wsserver.Options) *WSServerComponent {
+	t.Helper()
+	comp, err := component.Create(wsserver.Name)
+	if err != nil {
+		t.Fatalf("Failed to create component %q: %v", wsserver.Name, err)
+	}
+	if err := comp.Setup(mockEntity{}, &component.Config{
+		Name:    wsserver.Name,
+		Options: typing.NewRawObject(op.MustResult(json.Marshal(options))),
+	}, false); err != nil {
+		t.Fatalf("Failed to setup component %q: %v", wsserver.Name, err)
+	}
+	return comp.(*WSServerComponent)
+}
+
+// mustStart initializes and starts the component, and registers cleanup.
+func mustStart(t *testing.T, c *WSServerComponent) {
+	t.Helper()
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start component: %v", err)
+	}
+	t.Cleanup(func() {
+		c.Shutdown(context.Background())
+		c.Uninit(context.Background())
+	})
+}
+
+// dial connects to the WebSocket endpoint of the component.
+func dial(c *WSServerComponent, header http.Header) (*websocket.Conn, *http.Response, error) {
+	url := "ws://" + c.listener.Addr().String() + c.Options().Path
+	dialer := &websocket.Dialer{HandshakeTimeout: 5 * time.Second}
+	return dialer.Dial(url, header)
+}
+
+// echoSessionHandler echoes frames back and records closed sessions.
+type echoSessionHandler struct {
+	closed chan error
+}
+
+func (h *echoSessionHandler) OnOpen(s tcpserver.Session) {}
+
+func (h *echoSessionHandler) OnFrame(s tcpserver.Session, frame []byte) {
+	s.Send(frame)
+}
+
+func (h *echoSessionHandler) OnClose(s tcpserver.Session, err error) {
+	h.closed <- err
+}
+
+func TestSessionHandler(t *testing.T) {
+	c := mustNew(t, wsserver.Options{Addr: "127.0.0.1:0", Path: "/ws", TextFrames: true})
+	h := &echoSessionHandler{closed: make(chan error, 1)}
+	c.SetSessionHandler(h)
+	mustStart(t, c)
+
+	conn, _, err := dial(c, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []string{"ping", "pong"} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(want)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		if typ != websocket.TextMessage || string(data) != want {
+			t.Errorf("Expected text message %q, but got %q of type %d", want, data, typ)
+		}
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+	select {
+	case err := <-h.closed:
+		if err != nil {
+			t.Errorf("Unexpected close error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Session was not closed")
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	c := mustNew(t, wsserver.Options{Addr: "127.0.0.1:0", Path: "/ws", ReadTimeout: 1})
+	h := &echoSessionHandler{closed: make(chan error, 1)}
+	c.SetSessionHandler(h)
+	mustStart(t, c)
+
+	conn, _, err := dial(c, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	pings := make(chan struct{}, 8)
+	conn.SetPingHandler(func(data string) error {
+		pings <- struct{}{}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	frames := make(chan []byte, 1)
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				close(frames)
+				return
+			}
+			frames <- data
+		}
+	}()
+
+	// The idle client only answers pings, which must keep the session open
+	// past the read timeout.
+	time.Sleep(2500 * time.Millisecond)
+	if len(pings) < 2 {
+		t.Errorf("Expected at least 2 pings, but got %d", len(pings))
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte("hello")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	select {
+	case data, ok := <-frames:
+		if !ok || string(data) != "hello" {
+			t.Errorf("Expected %q, but got %q", "hello", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Frame was not echoed")
+	}
+	select {
+	case err := <-h.closed:
+		t.Errorf("Unexpected session close: %v", err)
+	default:
+	}
+}
+
+func TestFrameTooLarge(t *testing.T) {
+	c := mustNew(t, wsserver.Options{Addr: "127.0.0.1:0", Path: "/ws", MaxFrameSize: 4})
+	h := &echoSessionHandler{closed: make(chan error, 1)}
+	c.SetSessionHandler(h)
+	mustStart(t, c)
+
+	conn, _, err := dial(c, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.WriteMessage(websocket.BinaryMessage, []byte("too large"))
+	select {
+	case err := <-h.closed:
+		if err != tcpserver.ErrFrameTooLarge {
+			t.Errorf("Expected %v, but got %v", tcpserver.ErrFrameTooLarge, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Session was not closed")
+	}
+}
+
+func TestShutdownClosesSessions(t *testing.T) {
+	c := mustNew(t, wsserver.Options{Addr: "127.0.0.1:0", Path: "/ws"})
+	h := &echoSessionHandler{closed: make(chan error, 1)}
+	c.SetSessionHandler(h)
+	mustStart(t, c)
+
+	conn, _, err := dial(c, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	// Make sure the session is being served before shutting down.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	conn.WriteMessage(websocket.BinaryMessage, []byte("ping"))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Shutdown(ctx); err != nil {
+		t.Fatalf("Failed to shutdown: %v", err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("Expected normal closure, but got %v", err)
+	}
+	select {
+	case err := <-h.closed:
+		if err != nil {
+			t.Errorf("Unexpected close error: %v", err)
+		}
+	default:
+		t.Error("Expected session closed after shutdown")
+	}
+}
+
+// shutdownSessionHandler sends an oversized frame on open, and says goodbye
+// and closes the session on shutdown.
+type shutdownSessionHandler struct {
+	echoSessionHandler
+	sent chan error
+}
+
+func (h *shutdownSessionHandler) OnOpen(s tcpserver.Session) {
+	h.sent <- s.Send(make([]byte, 64))
+}
+
+func (h *shutdownSessionHandler) OnShutdown(s tcpserver.Session) {
+	s.Send([]byte("bye"))
+	s.Close()
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	c := mustNew(t, wsserver.Options{Addr: "127.0.0.1:0", Path: "/ws", MaxFrameSize: 16, GracefulShutdown: true})
+	h := &shutdownSessionHandler{
+		echoSessionHandler: echoSessionHandler{closed: make(chan error, 1)},
+		sent:               make(chan error, 1),
+	}
+	c.SetSessionHandler(h)
+	mustStart(t, c)
+
+	conn, _, err := dial(c, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	select {
+	case err := <-h.sent:
+		if !errors.Is(err, tcpserver.ErrFrameTooLarge) {
+			t.Errorf("Expected ErrFrameTooLarge, but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Session was not opened")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		c.Shutdown(ctx)
+	}()
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "bye" {
+		t.Errorf("Expected %q, but got %q, %v", "bye", data, err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("Expected normal closure, but got %v", err)
+	}
+	select {
+	case <-shutdown:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return after the session was closed")
+	}
+}
+
+func TestAllowedOrigins(t *testing.T) {
+	c := mustNew(t, wsserver.Options{
+		Addr:           "127.0.0.1:0",
+		Path:           "/ws",
+		AllowedOrigins: []string{"https://example.com"},
+	})
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"", true},
+		{"https://example.com", true},
+		{"HTTPS://EXAMPLE.COM", true},
+		{"https://evil.com", false},
+		{"http://example.com", false},
+	}
+	c.SetSessionHandler(&echoSessionHandler{closed: make(chan error, len(tests))})
+	mustStart(t, c)
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			conn, resp, err := dial(c, header)
+			if conn != nil {
+				conn.Close()
+			}
+			if tt.allowed && err != nil {
+				t.Errorf("Expected origin %q allowed, but got %v", tt.origin, err)
+			}
+			if !tt.allowed && (err == nil || resp == nil || resp.StatusCode != http.StatusForbidden) {
+				t.Errorf("Expected origin %q forbidden, but got %v", tt.origin, err)
+			}
+		})
+	}
+}
+
+func TestMissingAddrAndHTTPServer(t *testing.T) {
+	c := mustNew(t, wsserver.Options{Path: "/ws"})
+	if err := c.Init(context.Background()); err != nil {
+		t.Fatalf("Failed to init component: %v", err)
+	}
+	if err := c.Start(context.Background()); err == nil {
+		t.Error("Expected an error, but got nil")
+	}
+}